@@ -88,6 +88,8 @@ type (
 		reuseStatus int32
 		// cacheQuery the cache query
 		cacheQuery url.Values
+		// streamedSize the size of data which is streamed to response
+		streamedSize int64
 	}
 )
 
@@ -120,6 +122,7 @@ func (c *Context) Reset() {
 	c.clientIP = ""
 	c.reuseStatus = ReuseContextEnabled
 	c.cacheQuery = nil
+	c.streamedSize = 0
 }
 
 // GetRemoteAddr returns the remote addr of request
@@ -653,7 +656,9 @@ func (c *Context) Pipe(r io.Reader) (written int64, err error) {
 	if ok {
 		defer closer.Close()
 	}
-	return io.Copy(c.Response, r)
+	written, err = io.Copy(c.Response, r)
+	c.streamedSize += written
+	return
 }
 
 // IsReaderBody judgets whether body is reader
@@ -665,6 +670,15 @@ func (c *Context) IsReaderBody() bool {
 	return ok
 }
 
+// ResponseSize returns the size of response body,
+// it's the length of body buffer or the size of streamed data(pipe, stream json and so on).
+func (c *Context) ResponseSize() int {
+	if c.BodyBuffer != nil {
+		return c.BodyBuffer.Len()
+	}
+	return int(c.streamedSize)
+}
+
 // ServerTiming converts trace info to http response server timing
 func (c *Context) ServerTiming(traceInfos TraceInfos, prefix string) {
 	value := traceInfos.ServerTiming(prefix)
@@ -65,6 +65,18 @@ var (
 		Message:    "file not found",
 		Category:   ErrCategory,
 	}
+	// ErrRendererNotSet renderer is not set
+	ErrRendererNotSet = &hes.Error{
+		StatusCode: 500,
+		Message:    "renderer is not set",
+		Category:   ErrCategory,
+	}
+	// ErrInvalidPagination invalid pagination
+	ErrInvalidPagination = &hes.Error{
+		StatusCode: 400,
+		Message:    "invalid pagination",
+		Category:   ErrCategory,
+	}
 )
 
 // Version of elton
@@ -79,6 +91,8 @@ const (
 	HeaderXRealIP = "X-Real-Ip"
 	// HeaderSetCookie Set-Cookie
 	HeaderSetCookie = "Set-Cookie"
+	// HeaderCookie Cookie
+	HeaderCookie = "Cookie"
 	// HeaderLocation Location
 	HeaderLocation = "Location"
 	// HeaderContentType Content-Type
@@ -107,6 +121,10 @@ const (
 	HeaderServerTiming = "Server-Timing"
 	// HeaderTransferEncoding transfer encoding
 	HeaderTransferEncoding = "Transfer-Encoding"
+	// HeaderLink link
+	HeaderLink = "Link"
+	// HeaderXTotalCount x-total-count
+	HeaderXTotalCount = "X-Total-Count"
 
 	// MinRedirectCode min redirect code
 	MinRedirectCode = 300
@@ -115,8 +133,12 @@ const (
 
 	// MIMETextPlain text plain
 	MIMETextPlain = "text/plain; charset=utf-8"
+	// MIMETextHTML text html
+	MIMETextHTML = "text/html; charset=utf-8"
 	// MIMEApplicationJSON application json
 	MIMEApplicationJSON = "application/json; charset=utf-8"
+	// MIMEApplicationNDJSON newline delimited json
+	MIMEApplicationNDJSON = "application/x-ndjson"
 	// MIMEBinary binary data
 	MIMEBinary = "application/octet-stream"
 
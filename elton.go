@@ -74,6 +74,14 @@ type (
 		EnableTrace bool
 		// SignedKeys signed keys
 		SignedKeys SignedKeysGenerator
+		// Renderer the template renderer of Context.Render
+		Renderer Renderer
+		// ErrorRegistry the error registry which converts error to hes.Error,
+		// the error listeners will get the converted error with full details
+		ErrorRegistry *ErrorRegistry
+		// PanicHandler the handler of panic in ServeHTTP(pre handlers, router and so on),
+		// the panic will not be recovered if it's nil
+		PanicHandler PanicHandler
 
 		// status of elton
 		status int32
@@ -87,6 +95,7 @@ type (
 		preMiddlewares []PreHandler
 		errorListeners []ErrorListener
 		traceListeners []TraceListener
+		closeListeners []CloseListener
 		// functionInfos the function address:name map
 		functionInfos map[uintptr]string
 		ctxPool       sync.Pool
@@ -112,6 +121,8 @@ type (
 	}
 	// ErrorHandler error handle function
 	ErrorHandler func(*Context, error)
+	// PanicHandler panic handle function, the error is created by RecoverError
+	PanicHandler func(http.ResponseWriter, *http.Request, error)
 	// GenerateID generate context id
 	GenerateID func() string
 	// Handler elton handle function
@@ -120,6 +131,8 @@ type (
 	ErrorListener func(*Context, error)
 	// TraceListener trace listener
 	TraceListener func(*Context, TraceInfos)
+	// CloseListener close listener, it's used to release resources(e.g. flush log)
+	CloseListener func() error
 	// PreHandler pre handler
 	PreHandler func(*http.Request)
 )
@@ -215,12 +228,22 @@ func (e *Elton) Serve(l net.Listener) error {
 
 // Close closes the http server
 func (e *Elton) Close() error {
-	return e.Server.Close()
+	err := e.Server.Close()
+	closeErr := e.emitClose()
+	if err == nil {
+		err = closeErr
+	}
+	return err
 }
 
 // Shutdown shotdowns the http server
 func (e *Elton) Shutdown() error {
-	return e.Server.Shutdown(context.Background())
+	err := e.Server.Shutdown(context.Background())
+	closeErr := e.emitClose()
+	if err == nil {
+		err = closeErr
+	}
+	return err
 }
 
 // GracefulClose closes the http server graceful.
@@ -259,6 +282,19 @@ func (e *Elton) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
 		}
 		return
 	}
+	if e.PanicHandler != nil {
+		defer func() {
+			if r := recover(); r != nil {
+				// 与net/http一致，中止处理的panic不处理
+				if r == http.ErrAbortHandler {
+					panic(r)
+				}
+				err := RecoverError(r)
+				e.emitError(resp, req, err)
+				e.PanicHandler(resp, req, err)
+			}
+		}()
+	}
 	for _, preHandler := range e.preMiddlewares {
 		preHandler(req)
 	}
@@ -375,6 +411,9 @@ func (e *Elton) Handle(method, path string, handlerList ...Handler) *Elton {
 			e.EmitTrace(c, traceInfos)
 		}
 		if err != nil {
+			if e.ErrorRegistry != nil {
+				err = e.ErrorRegistry.Convert(err)
+			}
 			e.EmitError(c, err)
 		}
 		// 如果已commit 表示返回数据已设置，无需处理
@@ -528,6 +567,9 @@ func (e *Elton) error(c *Context, err error) *Elton {
 	}
 
 	resp := c.Response
+	if e.ErrorRegistry != nil {
+		err = e.ErrorRegistry.Public(e.ErrorRegistry.Convert(err))
+	}
 	he, ok := err.(*hes.Error)
 	status := http.StatusInternalServerError
 	message := err.Error()
@@ -586,6 +628,28 @@ func (e *Elton) OnTrace(ln TraceListener) *Elton {
 	return e
 }
 
+// emitClose emits the close event, it returns the first error of listen functions
+func (e *Elton) emitClose() error {
+	var err error
+	for _, ln := range e.closeListeners {
+		lnErr := ln()
+		if lnErr != nil && err == nil {
+			err = lnErr
+		}
+	}
+	return err
+}
+
+// OnClose adds listen to close event, the listen functions will be called
+// after the http server is closed(Close, Shutdown and GracefulClose)
+func (e *Elton) OnClose(ln CloseListener) *Elton {
+	if e.closeListeners == nil {
+		e.closeListeners = make([]CloseListener, 0)
+	}
+	e.closeListeners = append(e.closeListeners, ln)
+	return e
+}
+
 // AddGroup adds the group to elton
 func (e *Elton) AddGroup(groups ...*Group) *Elton {
 	for _, g := range groups {
@@ -370,6 +370,42 @@ func TestErrorHandler(t *testing.T) {
 	})
 }
 
+func TestPanicHandler(t *testing.T) {
+	assert := assert.New(t)
+	e := New()
+	var emitErr error
+	e.OnError(func(_ *Context, err error) {
+		emitErr = err
+	})
+	var panicErr error
+	e.PanicHandler = func(resp http.ResponseWriter, _ *http.Request, err error) {
+		panicErr = err
+		resp.WriteHeader(http.StatusInternalServerError)
+	}
+	e.Pre(func(req *http.Request) {
+		if req.URL.Path == "/abort" {
+			panic(http.ErrAbortHandler)
+		}
+		panic("pre panic")
+	})
+	e.GET("/", func(c *Context) error {
+		return nil
+	})
+
+	resp := httptest.NewRecorder()
+	e.ServeHTTP(resp, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(http.StatusInternalServerError, resp.Code)
+	assert.Equal("pre panic", panicErr.Error())
+	assert.Equal(panicErr, emitErr)
+	stack := ErrorStack(panicErr)
+	assert.True(strings.HasPrefix(stack[0], "github.com/vicanso/elton.TestPanicHandler."))
+
+	// 中止处理的panic不处理
+	assert.PanicsWithValue(http.ErrAbortHandler, func() {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/abort", nil))
+	})
+}
+
 func TestNotFoundHandler(t *testing.T) {
 	assert := assert.New(t)
 	e := New()
@@ -575,6 +611,28 @@ func TestGracefulClose(t *testing.T) {
 	})
 }
 
+func TestOnClose(t *testing.T) {
+	assert := assert.New(t)
+	e := New()
+	count := 0
+	closeErr := errors.New("close fail")
+	e.OnClose(func() error {
+		count++
+		return nil
+	})
+	e.OnClose(func() error {
+		count++
+		return closeErr
+	})
+	err := e.Shutdown()
+	assert.Equal(closeErr, err)
+	assert.Equal(2, count)
+
+	err = e.Close()
+	assert.Equal(closeErr, err)
+	assert.Equal(4, count)
+}
+
 // https://stackoverflow.com/questions/50120427/fail-unit-tests-if-coverage-is-below-certain-percentage
 func TestMain(m *testing.M) {
 	// call flag.Parse() here if TestMain uses flags
@@ -0,0 +1,315 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package elton
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/vicanso/hes"
+)
+
+const (
+	// ErrorStackKey the key of stack in the extra of hes.Error
+	ErrorStackKey = "stack"
+	// defaultStackDepth the max depth of stack
+	defaultStackDepth = 32
+)
+
+type (
+	// StackError the error with stack, it's created by WithStack
+	StackError struct {
+		Err   error
+		stack []uintptr
+	}
+	// ErrorMapping the mapping of error
+	ErrorMapping struct {
+		// StatusCode the status code of error, default is 500
+		StatusCode int
+		// Category the category of error
+		Category string
+		// Message the message of error, it will use the message of error if it's empty
+		Message string
+	}
+	// ErrorMatcher matches the error, it's used by error registry and problem registry
+	ErrorMatcher func(err error) bool
+	errorMatcher struct {
+		match   ErrorMatcher
+		mapping *ErrorMapping
+	}
+	// ErrorRegistryConfig error registry config
+	ErrorRegistryConfig struct {
+		// CaptureStack captures the stack of exception error(5xx),
+		// the stack is set to the extra of hes.Error
+		CaptureStack bool
+		// HideException hides the details of exception error for response,
+		// it should be true in production
+		HideException bool
+		// ExceptionMessage the message of exception error when it's hidden,
+		// default is the status text
+		ExceptionMessage string
+	}
+	// ErrorRegistry the registry of errors, it converts error to hes.Error
+	ErrorRegistry struct {
+		config   ErrorRegistryConfig
+		mutex    sync.RWMutex
+		matchers []*errorMatcher
+	}
+)
+
+// WithStack returns an error with the stack of caller,
+// the error will be returned if it has been with stack.
+func WithStack(err error) error {
+	if err == nil {
+		return nil
+	}
+	var se *StackError
+	if errors.As(err, &se) {
+		return err
+	}
+	return &StackError{
+		Err:   err,
+		stack: callers(3),
+	}
+}
+
+func callers(skip int) []uintptr {
+	pcs := make([]uintptr, defaultStackDepth)
+	n := runtime.Callers(skip, pcs)
+	return pcs[:n]
+}
+
+// Error returns the message of error
+func (se *StackError) Error() string {
+	return se.Err.Error()
+}
+
+// Unwrap returns the original error
+func (se *StackError) Unwrap() error {
+	return se.Err
+}
+
+// Stack returns the stack of error, each item is formatted as "function file:line"
+func (se *StackError) Stack() []string {
+	return formatStack(se.stack)
+}
+
+func formatStack(pcs []uintptr) []string {
+	stack := make([]string, 0, len(pcs))
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		// 忽略runtime的调用
+		if !strings.HasPrefix(frame.Function, "runtime.") {
+			stack = append(stack, fmt.Sprintf("%s %s:%d", frame.Function, frame.File, frame.Line))
+		}
+		if !more {
+			break
+		}
+	}
+	return stack
+}
+
+// RecoverError converts the recovered value of panic to error with the stack of panic,
+// it should be called in the deferred function which calls recover.
+func RecoverError(value interface{}) error {
+	err, ok := value.(error)
+	if !ok {
+		err = fmt.Errorf("%v", value)
+	}
+	// 忽略callers、RecoverError以及调用recover的函数
+	return &StackError{
+		Err:   err,
+		stack: callers(4),
+	}
+}
+
+// ErrorStack returns the stack of error, nil will be returned if the error is without stack
+func ErrorStack(err error) []string {
+	if he, ok := err.(*hes.Error); ok {
+		if stack, ok := he.Extra[ErrorStackKey].([]string); ok {
+			return stack
+		}
+		// hes.Error未实现Unwrap，使用原始的error
+		err = he.Err
+	}
+	var se *StackError
+	if err == nil || !errors.As(err, &se) {
+		return nil
+	}
+	return se.Stack()
+}
+
+// newErrorMatcher returns a matcher of error,
+// the original error of hes.Error is also matched because hes.Error doesn't implement Unwrap.
+func newErrorMatcher(match func(err error) bool) ErrorMatcher {
+	return func(err error) bool {
+		if err == nil {
+			return false
+		}
+		if match(err) {
+			return true
+		}
+		// hes.Error未实现Unwrap，因此需要使用原始的error匹配
+		if he, ok := err.(*hes.Error); ok && he.Err != nil {
+			return match(he.Err)
+		}
+		return false
+	}
+}
+
+// NewErrorMatcher returns a matcher which matches the error by errors.Is
+func NewErrorMatcher(target error) ErrorMatcher {
+	return newErrorMatcher(func(err error) bool {
+		return errors.Is(err, target)
+	})
+}
+
+// NewErrorTypeMatcher returns a matcher which matches the type of error by errors.As.
+// The target should be an error value of the type, e.g. (*os.PathError)(nil)
+func NewErrorTypeMatcher(target error) ErrorMatcher {
+	typ := reflect.TypeOf(target)
+	return newErrorMatcher(func(err error) bool {
+		return errors.As(err, reflect.New(typ).Interface())
+	})
+}
+
+// NewErrorRegistry returns a new error registry
+func NewErrorRegistry(config ErrorRegistryConfig) *ErrorRegistry {
+	return &ErrorRegistry{
+		config: config,
+	}
+}
+
+func (r *ErrorRegistry) addMatcher(match ErrorMatcher, mapping ErrorMapping) *ErrorRegistry {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.matchers = append(r.matchers, &errorMatcher{
+		match:   match,
+		mapping: &mapping,
+	})
+	return r
+}
+
+// Register registers the mapping for the error, it's matched by errors.Is
+func (r *ErrorRegistry) Register(target error, mapping ErrorMapping) *ErrorRegistry {
+	return r.addMatcher(NewErrorMatcher(target), mapping)
+}
+
+// RegisterType registers the mapping for the type of error, it's matched by errors.As.
+// The target should be an error value of the type, e.g. (*os.PathError)(nil)
+func (r *ErrorRegistry) RegisterType(target error, mapping ErrorMapping) *ErrorRegistry {
+	return r.addMatcher(NewErrorTypeMatcher(target), mapping)
+}
+
+// Lookup returns the mapping of error, nil will be returned if not found
+func (r *ErrorRegistry) Lookup(err error) *ErrorMapping {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	for _, matcher := range r.matchers {
+		if matcher.match(err) {
+			return matcher.mapping
+		}
+	}
+	return nil
+}
+
+// Convert converts the error to hes.Error with full details.
+// The hes.Error will be cloned, and other error will be converted by the mapping,
+// it will be an exception error(500) if the mapping is not found.
+// The stack will be captured for exception error if CaptureStack is true.
+func (r *ErrorRegistry) Convert(err error) *hes.Error {
+	var he *hes.Error
+	if e, ok := err.(*hes.Error); ok {
+		he = e.Clone()
+	} else {
+		he = &hes.Error{
+			StatusCode: http.StatusInternalServerError,
+			Message:    err.Error(),
+			Category:   ErrCategory,
+			Err:        err,
+		}
+		mapping := r.Lookup(err)
+		if mapping != nil {
+			if mapping.StatusCode != 0 {
+				he.StatusCode = mapping.StatusCode
+			}
+			if mapping.Category != "" {
+				he.Category = mapping.Category
+			}
+			if mapping.Message != "" {
+				he.Message = mapping.Message
+			}
+		}
+		he.Exception = he.StatusCode >= http.StatusInternalServerError
+	}
+	if r.config.CaptureStack && he.Exception && he.Extra[ErrorStackKey] == nil {
+		stack := ErrorStack(err)
+		// 如果未包括stack，则使用当前调用的stack
+		if stack == nil {
+			stack = formatStack(callers(3))
+		}
+		extra := make(map[string]interface{}, len(he.Extra)+1)
+		for k, v := range he.Extra {
+			extra[k] = v
+		}
+		extra[ErrorStackKey] = stack
+		he.Extra = extra
+	}
+	return he
+}
+
+// Public returns the hes.Error for response, the stack is removed,
+// and the details of exception error are hidden if HideException is true.
+func (r *ErrorRegistry) Public(he *hes.Error) *hes.Error {
+	if r.config.HideException && he.Exception {
+		message := r.config.ExceptionMessage
+		if message == "" {
+			message = http.StatusText(he.StatusCode)
+		}
+		return &hes.Error{
+			StatusCode: he.StatusCode,
+			Category:   he.Category,
+			Message:    message,
+			Exception:  true,
+		}
+	}
+	if _, ok := he.Extra[ErrorStackKey]; !ok {
+		return he
+	}
+	result := *he
+	result.Extra = make(map[string]interface{}, len(he.Extra))
+	for k, v := range he.Extra {
+		if k != ErrorStackKey {
+			result.Extra[k] = v
+		}
+	}
+	if len(result.Extra) == 0 {
+		result.Extra = nil
+	}
+	return &result
+}
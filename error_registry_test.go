@@ -0,0 +1,190 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package elton
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vicanso/hes"
+)
+
+func TestWithStack(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(WithStack(nil))
+	err := WithStack(sql.ErrNoRows)
+	assert.Equal(sql.ErrNoRows.Error(), err.Error())
+	assert.True(errors.Is(err, sql.ErrNoRows))
+	// 已包括stack则不再添加
+	assert.Equal(err, WithStack(err))
+	wrapErr := fmt.Errorf("get user fail: %w", err)
+	assert.Equal(wrapErr, WithStack(wrapErr))
+
+	stack := ErrorStack(wrapErr)
+	assert.NotEmpty(stack)
+	assert.True(strings.HasPrefix(stack[0], "github.com/vicanso/elton.TestWithStack "))
+	assert.Nil(ErrorStack(errors.New("abc")))
+	assert.Equal(stack, ErrorStack(hes.Wrap(err)))
+}
+
+func TestErrorMatcher(t *testing.T) {
+	assert := assert.New(t)
+
+	match := NewErrorMatcher(sql.ErrNoRows)
+	assert.False(match(nil))
+	assert.False(match(errors.New("abc")))
+	assert.True(match(fmt.Errorf("get user fail: %w", sql.ErrNoRows)))
+	// hes.Error使用原始的error匹配
+	assert.True(match(hes.Wrap(sql.ErrNoRows)))
+
+	_, err := os.Open("/not-exists-file")
+	match = NewErrorTypeMatcher((*os.PathError)(nil))
+	assert.False(match(errors.New("abc")))
+	assert.True(match(err))
+	assert.True(match(hes.Wrap(WithStack(err))))
+}
+
+func TestErrorRegistry(t *testing.T) {
+	assert := assert.New(t)
+
+	r := NewErrorRegistry(ErrorRegistryConfig{
+		CaptureStack: true,
+	}).Register(sql.ErrNoRows, ErrorMapping{
+		StatusCode: 404,
+		Message:    "not found",
+		Category:   "db",
+	}).RegisterType((*os.PathError)(nil), ErrorMapping{
+		Category: "fs",
+	})
+
+	assert.Nil(r.Lookup(errors.New("abc")))
+	assert.Equal("db", r.Lookup(hes.Wrap(sql.ErrNoRows)).Category)
+
+	he := r.Convert(fmt.Errorf("get user fail: %w", sql.ErrNoRows))
+	assert.Equal(404, he.StatusCode)
+	assert.Equal("not found", he.Message)
+	assert.Equal("db", he.Category)
+	assert.False(he.Exception)
+	assert.Nil(he.Extra)
+	assert.True(errors.Is(he.Err, sql.ErrNoRows))
+
+	_, err := os.Open("/not-exists-file")
+	he = r.Convert(err)
+	assert.Equal(500, he.StatusCode)
+	assert.Equal(err.Error(), he.Message)
+	assert.Equal("fs", he.Category)
+	assert.True(he.Exception)
+	// 未包括stack，使用当前调用的stack
+	assert.NotEmpty(he.Extra[ErrorStackKey])
+	assert.Equal(he.Extra[ErrorStackKey], ErrorStack(he))
+
+	err = WithStack(errors.New("abc"))
+	he = r.Convert(err)
+	assert.Equal(ErrCategory, he.Category)
+	assert.Equal(ErrorStack(err), he.Extra[ErrorStackKey])
+
+	// hes error
+	originalErr := hes.New("abc")
+	he = r.Convert(originalErr)
+	assert.Equal(originalErr, he)
+	assert.NotSame(originalErr, he)
+}
+
+func TestErrorRegistryPublic(t *testing.T) {
+	assert := assert.New(t)
+
+	he := &hes.Error{
+		StatusCode: 500,
+		Message:    "connect to db fail",
+		Category:   "db",
+		Exception:  true,
+		Extra: map[string]interface{}{
+			ErrorStackKey: []string{"main.main"},
+			"id":          1,
+		},
+	}
+	r := NewErrorRegistry(ErrorRegistryConfig{})
+	result := r.Public(he)
+	assert.Equal("connect to db fail", result.Message)
+	assert.Equal(map[string]interface{}{
+		"id": 1,
+	}, result.Extra)
+	// 原有的出错不修改
+	assert.NotNil(he.Extra[ErrorStackKey])
+
+	r = NewErrorRegistry(ErrorRegistryConfig{
+		HideException: true,
+	})
+	assert.Equal(&hes.Error{
+		StatusCode: 500,
+		Message:    "Internal Server Error",
+		Category:   "db",
+		Exception:  true,
+	}, r.Public(he))
+	// 非exception的出错不隐藏
+	notFound := hes.NewWithStatusCode("user not found", 404)
+	assert.Equal(notFound, r.Public(notFound))
+}
+
+func TestEltonErrorRegistry(t *testing.T) {
+	assert := assert.New(t)
+
+	e := New()
+	e.ErrorRegistry = NewErrorRegistry(ErrorRegistryConfig{
+		CaptureStack:  true,
+		HideException: true,
+	}).Register(sql.ErrNoRows, ErrorMapping{
+		StatusCode: 404,
+	})
+	var emitErr error
+	e.OnError(func(_ *Context, err error) {
+		emitErr = err
+	})
+	e.GET("/users/{id}", func(c *Context) error {
+		if c.Param("id") == "1" {
+			return sql.ErrNoRows
+		}
+		return WithStack(errors.New("connect to db fail"))
+	})
+
+	resp := httptest.NewRecorder()
+	e.ServeHTTP(resp, httptest.NewRequest("GET", "/users/1", nil))
+	assert.Equal(404, resp.Code)
+	assert.Equal("statusCode=404, category=elton, message="+sql.ErrNoRows.Error(), resp.Body.String())
+
+	resp = httptest.NewRecorder()
+	e.ServeHTTP(resp, httptest.NewRequest("GET", "/users/2", nil))
+	assert.Equal(500, resp.Code)
+	assert.Equal("statusCode=500, category=elton, message=Internal Server Error", resp.Body.String())
+	// 出错事件获取完整的出错信息
+	he, ok := emitErr.(*hes.Error)
+	assert.True(ok)
+	assert.Equal("connect to db fail", he.Message)
+	assert.NotEmpty(ErrorStack(he))
+}
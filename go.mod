@@ -3,9 +3,12 @@ module github.com/vicanso/elton
 go 1.16
 
 require (
+	github.com/andybalholm/brotli v1.1.0
+	github.com/klauspost/compress v1.15.9
 	github.com/stretchr/testify v1.7.0
 	github.com/tidwall/gjson v1.8.1
 	github.com/vicanso/hes v0.3.9
 	github.com/vicanso/intranet-ip v0.0.1
 	github.com/vicanso/keygrip v1.2.1
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,121 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/vicanso/elton"
+	"github.com/vicanso/hes"
+)
+
+const (
+	// ErrAPIKeyAuthCategory api key auth error category
+	ErrAPIKeyAuthCategory = "elton-api-key-auth"
+	// DefaultAPIKeyHeader default header of api key
+	DefaultAPIKeyHeader = "X-API-Key"
+	// DefaultAuthIdentityKey default context key of caller identity
+	DefaultAuthIdentityKey = "authIdentity"
+)
+
+type (
+	// APIKeyLookup lookup function, it returns the identity of api key,
+	// the api key is invalid if valid is false
+	APIKeyLookup func(key string, c *elton.Context) (identity string, valid bool, err error)
+	// APIKeyAuthConfig api key auth config
+	APIKeyAuthConfig struct {
+		// Header the header name of api key, default is X-API-Key
+		Header string
+		// Query the query name of api key, it will be used if header is empty
+		Query string
+		// Lookup lookup function
+		Lookup APIKeyLookup
+		// IdentityKey the key of identity for context, default is authIdentity
+		IdentityKey string
+		Skipper     elton.Skipper
+	}
+)
+
+var (
+	// ErrAPIKeyUnauthorized unauthorized error
+	ErrAPIKeyUnauthorized = &hes.Error{
+		StatusCode: http.StatusUnauthorized,
+		Message:    "api key is invalid",
+		Category:   ErrAPIKeyAuthCategory,
+	}
+	// ErrAPIKeyRequireLookupFunction require lookup function
+	ErrAPIKeyRequireLookupFunction = errors.New("require lookup function")
+)
+
+// NewAPIKeyAuth returns a new api key auth middleware,
+// it gets api key from header or query and validate it by lookup function.
+// The identity of caller will be set to context, it can be got by c.GetString(IdentityKey).
+// It will throw a panic if the lookup function is nil.
+func NewAPIKeyAuth(config APIKeyAuthConfig) elton.Handler {
+	if config.Lookup == nil {
+		panic(ErrAPIKeyRequireLookupFunction)
+	}
+	header := config.Header
+	if header == "" {
+		header = DefaultAPIKeyHeader
+	}
+	identityKey := config.IdentityKey
+	if identityKey == "" {
+		identityKey = DefaultAuthIdentityKey
+	}
+	skipper := config.Skipper
+	if skipper == nil {
+		skipper = elton.DefaultSkipper
+	}
+	return func(c *elton.Context) (err error) {
+		if skipper(c) || c.Request.Method == http.MethodOptions {
+			return c.Next()
+		}
+		key := c.GetRequestHeader(header)
+		if key == "" && config.Query != "" {
+			key = c.QueryParam(config.Query)
+		}
+		if key == "" {
+			err = ErrAPIKeyUnauthorized
+			return
+		}
+		identity, valid, e := config.Lookup(key, c)
+		if e != nil {
+			he, ok := e.(*hes.Error)
+			if !ok {
+				he = hes.Wrap(e)
+				he.StatusCode = http.StatusBadRequest
+				he.Category = ErrAPIKeyAuthCategory
+			}
+			err = he
+			return
+		}
+		if !valid {
+			err = ErrAPIKeyUnauthorized
+			return
+		}
+		c.Set(identityKey, identity)
+		return c.Next()
+	}
+}
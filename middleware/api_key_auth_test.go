@@ -0,0 +1,142 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vicanso/elton"
+	"github.com/vicanso/hes"
+)
+
+func TestNoLookupPanic(t *testing.T) {
+	assert := assert.New(t)
+	defer func() {
+		r := recover()
+		assert.NotNil(r)
+		assert.Equal(ErrAPIKeyRequireLookupFunction, r.(error))
+	}()
+
+	NewAPIKeyAuth(APIKeyAuthConfig{})
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	assert := assert.New(t)
+	skipErr := errors.New("skip error")
+	// next直接返回skip error，用于判断是否执行了next
+	next := func() error {
+		return skipErr
+	}
+	defaultAuth := NewAPIKeyAuth(APIKeyAuthConfig{
+		Query: "apiKey",
+		Lookup: func(key string, c *elton.Context) (string, bool, error) {
+			if key == "error" {
+				return "", false, errors.New("lookup error")
+			}
+			if key == "abcd" {
+				return "tree.xie", true, nil
+			}
+			return "", false, nil
+		},
+	})
+	tests := []struct {
+		newContext func() *elton.Context
+		err        error
+		identity   string
+	}{
+		// committed: true
+		{
+			newContext: func() *elton.Context {
+				c := elton.NewContext(httptest.NewRecorder(), nil)
+				c.Committed = true
+				c.Next = next
+				return c
+			},
+			err: skipErr,
+		},
+		// no api key
+		{
+			newContext: func() *elton.Context {
+				return elton.NewContext(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+			},
+			err: ErrAPIKeyUnauthorized,
+		},
+		// lookup error
+		{
+			newContext: func() *elton.Context {
+				req := httptest.NewRequest("GET", "/", nil)
+				req.Header.Set(DefaultAPIKeyHeader, "error")
+				return elton.NewContext(httptest.NewRecorder(), req)
+			},
+			err: &hes.Error{
+				StatusCode: http.StatusBadRequest,
+				Message:    "lookup error",
+				Category:   ErrAPIKeyAuthCategory,
+				Err:        errors.New("lookup error"),
+			},
+		},
+		// invalid api key
+		{
+			newContext: func() *elton.Context {
+				req := httptest.NewRequest("GET", "/", nil)
+				req.Header.Set(DefaultAPIKeyHeader, "1234")
+				return elton.NewContext(httptest.NewRecorder(), req)
+			},
+			err: ErrAPIKeyUnauthorized,
+		},
+		// api key from header
+		{
+			newContext: func() *elton.Context {
+				req := httptest.NewRequest("GET", "/", nil)
+				req.Header.Set(DefaultAPIKeyHeader, "abcd")
+				c := elton.NewContext(httptest.NewRecorder(), req)
+				c.Next = next
+				return c
+			},
+			err:      skipErr,
+			identity: "tree.xie",
+		},
+		// api key from query
+		{
+			newContext: func() *elton.Context {
+				req := httptest.NewRequest("GET", "/?apiKey=abcd", nil)
+				c := elton.NewContext(httptest.NewRecorder(), req)
+				c.Next = next
+				return c
+			},
+			err:      skipErr,
+			identity: "tree.xie",
+		},
+	}
+
+	for _, tt := range tests {
+		c := tt.newContext()
+		err := defaultAuth(c)
+		assert.Equal(tt.err, err)
+		assert.Equal(tt.identity, c.GetString(DefaultAuthIdentityKey))
+	}
+}
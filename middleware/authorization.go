@@ -0,0 +1,185 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/vicanso/elton"
+	"github.com/vicanso/hes"
+)
+
+const (
+	// ErrAuthorizationCategory authorization error category
+	ErrAuthorizationCategory = "elton-authorization"
+)
+
+type (
+	// AuthorizationSubjectGetter returns the roles and scopes of caller
+	AuthorizationSubjectGetter func(c *elton.Context) (roles []string, scopes []string, err error)
+	// AuthorizationPolicy authorization policy of route
+	AuthorizationPolicy struct {
+		// Roles the caller should have any of roles
+		Roles []string
+		// Scopes the caller should have all of scopes
+		Scopes []string
+		// Validate custom validate function
+		Validate func(c *elton.Context) (bool, error)
+	}
+	// AuthorizationPolicies policies of routes, the key is "method route", e.g. "GET /users/:id"
+	AuthorizationPolicies map[string]*AuthorizationPolicy
+	// AuthorizationConfig authorization config
+	AuthorizationConfig struct {
+		// Policies policies of routes
+		Policies AuthorizationPolicies
+		// GetSubject get subject function
+		GetSubject AuthorizationSubjectGetter
+		// DenyWithoutPolicy deny the request if the route has no policy
+		DenyWithoutPolicy bool
+		Skipper           elton.Skipper
+	}
+)
+
+var (
+	// ErrAuthorizationForbidden forbidden error
+	ErrAuthorizationForbidden = &hes.Error{
+		StatusCode: http.StatusForbidden,
+		Message:    "permission denied",
+		Category:   ErrAuthorizationCategory,
+	}
+	// ErrAuthorizationRequireSubjectFunction require get subject function
+	ErrAuthorizationRequireSubjectFunction = errors.New("require get subject function")
+)
+
+func getAuthorizationKey(method, route string) string {
+	return method + " " + route
+}
+
+// Add adds policy for the route
+func (policies AuthorizationPolicies) Add(method, route string, policy *AuthorizationPolicy) AuthorizationPolicies {
+	policies[getAuthorizationKey(method, route)] = policy
+	return policies
+}
+
+// Get returns the policy of route
+func (policies AuthorizationPolicies) Get(method, route string) *AuthorizationPolicy {
+	return policies[getAuthorizationKey(method, route)]
+}
+
+// UncoveredRouters returns the routers which have no policy,
+// it can be used to check all routers of elton(e.GetRouters()) are protected.
+func (policies AuthorizationPolicies) UncoveredRouters(routers []elton.RouterInfo) []elton.RouterInfo {
+	result := make([]elton.RouterInfo, 0)
+	for _, r := range routers {
+		if policies.Get(r.Method, r.Route) == nil {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+func containsAny(values []string, expected []string) bool {
+	for _, v := range expected {
+		if containsString(values, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAll(values []string, expected []string) bool {
+	for _, v := range expected {
+		if !containsString(values, v) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// NewAuthorization returns a new authorization middleware, it checks the policy of route(c.Route and method).
+// The request will be forbidden if the caller does not have any role of policy or all scopes of policy,
+// or the validate function of policy returns false.
+// It will throw a panic if the get subject function is nil.
+func NewAuthorization(config AuthorizationConfig) elton.Handler {
+	if config.GetSubject == nil {
+		panic(ErrAuthorizationRequireSubjectFunction)
+	}
+	policies := config.Policies
+	if policies == nil {
+		policies = make(AuthorizationPolicies)
+	}
+	skipper := config.Skipper
+	if skipper == nil {
+		skipper = elton.DefaultSkipper
+	}
+	return func(c *elton.Context) (err error) {
+		if skipper(c) || c.Request.Method == http.MethodOptions {
+			return c.Next()
+		}
+		policy := policies.Get(c.Request.Method, c.Route)
+		if policy == nil {
+			if config.DenyWithoutPolicy {
+				err = ErrAuthorizationForbidden
+				return
+			}
+			return c.Next()
+		}
+		if len(policy.Roles) != 0 || len(policy.Scopes) != 0 {
+			roles, scopes, e := config.GetSubject(c)
+			if e != nil {
+				err = hes.Wrap(e)
+				return
+			}
+			if len(policy.Roles) != 0 && !containsAny(roles, policy.Roles) {
+				err = ErrAuthorizationForbidden
+				return
+			}
+			if !containsAll(scopes, policy.Scopes) {
+				err = ErrAuthorizationForbidden
+				return
+			}
+		}
+		if policy.Validate != nil {
+			valid, e := policy.Validate(c)
+			if e != nil {
+				err = hes.Wrap(e)
+				return
+			}
+			if !valid {
+				err = ErrAuthorizationForbidden
+				return
+			}
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,179 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vicanso/elton"
+)
+
+func TestAuthorizationPolicies(t *testing.T) {
+	assert := assert.New(t)
+	policies := make(AuthorizationPolicies)
+	policies.Add("GET", "/users/:id", &AuthorizationPolicy{
+		Roles: []string{"admin"},
+	})
+	assert.NotNil(policies.Get("GET", "/users/:id"))
+	assert.Nil(policies.Get("POST", "/users/:id"))
+
+	e := elton.New()
+	noop := func(c *elton.Context) error {
+		return nil
+	}
+	e.GET("/users/:id", noop)
+	e.POST("/users/:id", noop)
+	e.GET("/books", noop)
+	assert.Equal([]elton.RouterInfo{
+		{
+			Method: "POST",
+			Route:  "/users/:id",
+		},
+		{
+			Method: "GET",
+			Route:  "/books",
+		},
+	}, policies.UncoveredRouters(e.GetRouters()))
+}
+
+func TestAuthorizationNoSubjectPanic(t *testing.T) {
+	assert := assert.New(t)
+	defer func() {
+		r := recover()
+		assert.Equal(ErrAuthorizationRequireSubjectFunction, r.(error))
+	}()
+	NewAuthorization(AuthorizationConfig{})
+}
+
+func TestAuthorization(t *testing.T) {
+	assert := assert.New(t)
+	skipErr := errors.New("skip error")
+	policies := make(AuthorizationPolicies).
+		Add("GET", "/users", &AuthorizationPolicy{
+			Roles: []string{"admin", "su"},
+		}).
+		Add("POST", "/users", &AuthorizationPolicy{
+			Scopes: []string{"user:read", "user:write"},
+		}).
+		Add("GET", "/books/:id", &AuthorizationPolicy{
+			Validate: func(c *elton.Context) (bool, error) {
+				return c.Param("id") == "1", nil
+			},
+		})
+	getSubject := func(c *elton.Context) ([]string, []string, error) {
+		return []string{c.GetRequestHeader("X-Role")}, []string{"user:read"}, nil
+	}
+	fn := NewAuthorization(AuthorizationConfig{
+		Policies:   policies,
+		GetSubject: getSubject,
+	})
+	newContext := func(method, route, role string) *elton.Context {
+		req := httptest.NewRequest(method, "/", nil)
+		req.Header.Set("X-Role", role)
+		c := elton.NewContext(httptest.NewRecorder(), req)
+		c.Route = route
+		c.Next = func() error {
+			return skipErr
+		}
+		return c
+	}
+
+	tests := []struct {
+		newContext func() *elton.Context
+		fn         elton.Handler
+		err        error
+	}{
+		// role match
+		{
+			newContext: func() *elton.Context {
+				return newContext("GET", "/users", "su")
+			},
+			fn:  fn,
+			err: skipErr,
+		},
+		// role not match
+		{
+			newContext: func() *elton.Context {
+				return newContext("GET", "/users", "guest")
+			},
+			fn:  fn,
+			err: ErrAuthorizationForbidden,
+		},
+		// scopes not match
+		{
+			newContext: func() *elton.Context {
+				return newContext("POST", "/users", "admin")
+			},
+			fn:  fn,
+			err: ErrAuthorizationForbidden,
+		},
+		// validate success
+		{
+			newContext: func() *elton.Context {
+				c := newContext("GET", "/books/:id", "")
+				c.Params.Add("id", "1")
+				return c
+			},
+			fn:  fn,
+			err: skipErr,
+		},
+		// validate fail
+		{
+			newContext: func() *elton.Context {
+				c := newContext("GET", "/books/:id", "")
+				c.Params.Add("id", "2")
+				return c
+			},
+			fn:  fn,
+			err: ErrAuthorizationForbidden,
+		},
+		// no policy
+		{
+			newContext: func() *elton.Context {
+				return newContext("GET", "/", "")
+			},
+			fn:  fn,
+			err: skipErr,
+		},
+		// deny without policy
+		{
+			newContext: func() *elton.Context {
+				return newContext("GET", "/", "")
+			},
+			fn: NewAuthorization(AuthorizationConfig{
+				Policies:          policies,
+				GetSubject:        getSubject,
+				DenyWithoutPolicy: true,
+			}),
+			err: ErrAuthorizationForbidden,
+		},
+	}
+	for _, tt := range tests {
+		c := tt.newContext()
+		err := tt.fn(c)
+		assert.Equal(tt.err, err)
+	}
+}
@@ -0,0 +1,122 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/vicanso/elton"
+)
+
+const (
+	// BrEncoding br encoding
+	BrEncoding = "br"
+)
+
+type (
+	// BrCompressor brotli compress
+	BrCompressor struct {
+		Level     int
+		MinLength int
+		pool      sync.Pool
+	}
+)
+
+// Accept accept br encoding
+func (b *BrCompressor) Accept(c *elton.Context, bodySize int) (acceptable bool, encoding string) {
+	// 如果数据少于最低压缩长度，则不压缩（reader的bodySize为-1）
+	if bodySize >= 0 && bodySize < b.getMinLength() {
+		return
+	}
+	return AcceptEncoding(c, BrEncoding)
+}
+
+// Compress compress data by brotli
+func (b *BrCompressor) Compress(buf []byte) (*bytes.Buffer, error) {
+	buffer := new(bytes.Buffer)
+	w := b.Writer(buffer)
+	defer b.Release(w)
+	_, err := w.Write(buf)
+	if err != nil {
+		return nil, err
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buffer, nil
+}
+
+// Writer returns a brotli writer from pool
+func (b *BrCompressor) Writer(w io.Writer) CompressWriter {
+	if v := b.pool.Get(); v != nil {
+		bw := v.(*brotli.Writer)
+		bw.Reset(w)
+		return bw
+	}
+	return brotli.NewWriterLevel(w, b.getLevel())
+}
+
+// Release puts the brotli writer back to pool
+func (b *BrCompressor) Release(w CompressWriter) {
+	w.Reset(ioutil.Discard)
+	b.pool.Put(w)
+}
+
+func (b *BrCompressor) getLevel() int {
+	level := b.Level
+	if level <= 0 {
+		level = brotli.DefaultCompression
+	}
+	if level > brotli.BestCompression {
+		level = brotli.BestCompression
+	}
+	return level
+}
+
+func (b *BrCompressor) getMinLength() int {
+	if b.MinLength == 0 {
+		return DefaultCompressMinLength
+	}
+	return b.MinLength
+}
+
+// Pipe compress by pipe
+func (b *BrCompressor) Pipe(c *elton.Context) (err error) {
+	r := c.Body.(io.Reader)
+	closer, ok := c.Body.(io.Closer)
+	if ok {
+		defer closer.Close()
+	}
+	w := b.Writer(c.Response)
+	defer b.Release(w)
+	_, err = io.Copy(w, r)
+	if err != nil {
+		return
+	}
+	return w.Close()
+}
@@ -0,0 +1,452 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"bytes"
+	"container/list"
+	gocontext "context"
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vicanso/elton"
+)
+
+const (
+	// HeaderAge Age
+	HeaderAge = "Age"
+	// HeaderVary Vary
+	HeaderVary = "Vary"
+	// HeaderXCache X-Cache, the value is HIT, STALE or MISS
+	HeaderXCache = "X-Cache"
+
+	cacheHit   = "HIT"
+	cacheStale = "STALE"
+	cacheMiss  = "MISS"
+
+	defaultCacheMaxEntries        = 1000
+	defaultCacheRevalidateTimeout = 30 * time.Second
+)
+
+type (
+	// CachedResponse the cached response, it includes status, header and body
+	CachedResponse struct {
+		StatusCode int         `json:"statusCode,omitempty"`
+		Header     http.Header `json:"header,omitempty"`
+		Body       []byte      `json:"body,omitempty"`
+		// Vary the vary headers of response, it's only used by cache middleware
+		Vary []string `json:"vary,omitempty"`
+		// CreatedAt create time of response
+		CreatedAt time.Time `json:"createdAt,omitempty"`
+		// MaxAge the fresh duration of response
+		MaxAge time.Duration `json:"maxAge,omitempty"`
+		// StaleWhileRevalidate the duration of stale response can be used while revalidating
+		StaleWhileRevalidate time.Duration `json:"staleWhileRevalidate,omitempty"`
+	}
+	// CacheStore cache store
+	CacheStore interface {
+		// Get returns the cached response, returns nil if not exists
+		Get(key string) (*CachedResponse, error)
+		// Set sets the cached response with ttl
+		Set(key string, resp *CachedResponse, ttl time.Duration) error
+	}
+	// CacheConfig cache config
+	CacheConfig struct {
+		// Store cache store, default is lru store(1000 entries)
+		Store CacheStore
+		// RevalidateTimeout the timeout of background revalidation, default is 30 seconds
+		RevalidateTimeout time.Duration
+		Skipper           elton.Skipper
+	}
+	lruCacheEntry struct {
+		key       string
+		resp      *CachedResponse
+		expiredAt time.Time
+	}
+	// LRUCacheStore lru cache store
+	LRUCacheStore struct {
+		mutex      sync.Mutex
+		maxEntries int
+		ll         *list.List
+		m          map[string]*list.Element
+		now        func() time.Time
+	}
+	// nopResponseWriter discards the response, it's used for background revalidation
+	nopResponseWriter struct {
+		header http.Header
+	}
+	cacheControl struct {
+		noStore              bool
+		noCache              bool
+		private              bool
+		public               bool
+		mustRevalidate       bool
+		maxAge               int
+		sMaxAge              int
+		staleWhileRevalidate int
+	}
+	// cacheRevalidateKey the context key of background revalidation
+	cacheRevalidateKey struct{}
+)
+
+var cacheableStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusPermanentRedirect:    true,
+	http.StatusNotFound:             true,
+	http.StatusGone:                 true,
+}
+
+func newNopResponseWriter() *nopResponseWriter {
+	return &nopResponseWriter{
+		header: make(http.Header),
+	}
+}
+
+func (w *nopResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *nopResponseWriter) Write(buf []byte) (int, error) {
+	return len(buf), nil
+}
+
+func (w *nopResponseWriter) WriteHeader(statusCode int) {}
+
+// newCachedResponse creates a cached response from context
+func newCachedResponse(c *elton.Context) *CachedResponse {
+	statusCode := c.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	var body []byte
+	if c.BodyBuffer != nil {
+		body = append([]byte(nil), c.BodyBuffer.Bytes()...)
+	}
+	return &CachedResponse{
+		StatusCode: statusCode,
+		Header:     c.Header().Clone(),
+		Body:       body,
+	}
+}
+
+// applyTo sets the status, header and body of cached response to context
+func (resp *CachedResponse) applyTo(c *elton.Context) {
+	for key, values := range resp.Header {
+		c.Header()[key] = append([]string(nil), values...)
+	}
+	c.StatusCode = resp.StatusCode
+	c.Body = nil
+	c.BodyBuffer = nil
+	if resp.Body != nil {
+		// 限制cap，避免对buffer的写入修改缓存的数据
+		c.BodyBuffer = bytes.NewBuffer(resp.Body[:len(resp.Body):len(resp.Body)])
+	}
+}
+
+// Age returns the age of response
+func (resp *CachedResponse) Age(now time.Time) time.Duration {
+	return now.Sub(resp.CreatedAt)
+}
+
+// Fresh returns true if the response is fresh
+func (resp *CachedResponse) Fresh(now time.Time) bool {
+	return resp.Age(now) < resp.MaxAge
+}
+
+// NewLRUCacheStore returns a new lru cache store, the default max entries is 1000
+func NewLRUCacheStore(maxEntries int) *LRUCacheStore {
+	if maxEntries <= 0 {
+		maxEntries = defaultCacheMaxEntries
+	}
+	return &LRUCacheStore{
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		m:          make(map[string]*list.Element),
+		now:        time.Now,
+	}
+}
+
+// Get returns the cached response of key
+func (s *LRUCacheStore) Get(key string) (*CachedResponse, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	e, ok := s.m[key]
+	if !ok {
+		return nil, nil
+	}
+	entry := e.Value.(*lruCacheEntry)
+	if s.now().After(entry.expiredAt) {
+		s.ll.Remove(e)
+		delete(s.m, key)
+		return nil, nil
+	}
+	s.ll.MoveToFront(e)
+	return entry.resp, nil
+}
+
+// Set sets the cached response of key, the oldest entry will be removed if the entries is over the max
+func (s *LRUCacheStore) Set(key string, resp *CachedResponse, ttl time.Duration) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	expiredAt := s.now().Add(ttl)
+	if e, ok := s.m[key]; ok {
+		entry := e.Value.(*lruCacheEntry)
+		entry.resp = resp
+		entry.expiredAt = expiredAt
+		s.ll.MoveToFront(e)
+		return nil
+	}
+	s.m[key] = s.ll.PushFront(&lruCacheEntry{
+		key:       key,
+		resp:      resp,
+		expiredAt: expiredAt,
+	})
+	for s.ll.Len() > s.maxEntries {
+		e := s.ll.Back()
+		s.ll.Remove(e)
+		delete(s.m, e.Value.(*lruCacheEntry).key)
+	}
+	return nil
+}
+
+// Len returns the count of entries
+func (s *LRUCacheStore) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.ll.Len()
+}
+
+func parseCacheControl(value string) *cacheControl {
+	cc := &cacheControl{
+		maxAge:  -1,
+		sMaxAge: -1,
+	}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		name := item
+		v := ""
+		if index := strings.IndexByte(item, '='); index > 0 {
+			name = item[:index]
+			v = strings.Trim(item[index+1:], `"`)
+		}
+		switch name {
+		case "no-store":
+			cc.noStore = true
+		case "no-cache":
+			cc.noCache = true
+		case "private":
+			cc.private = true
+		case "public":
+			cc.public = true
+		case "must-revalidate":
+			cc.mustRevalidate = true
+		case "max-age":
+			cc.maxAge, _ = strconv.Atoi(v)
+		case "s-maxage":
+			cc.sMaxAge, _ = strconv.Atoi(v)
+		case "stale-while-revalidate":
+			cc.staleWhileRevalidate, _ = strconv.Atoi(v)
+		}
+	}
+	return cc
+}
+
+// allowAuthorization checks whether the response of request with Authorization
+// can be stored and served by shared cache(RFC 7234 3.2)
+func (cc *cacheControl) allowAuthorization() bool {
+	return cc.public || cc.mustRevalidate || cc.sMaxAge >= 0
+}
+
+// getVaryHeaders returns the sorted vary headers of response
+func getVaryHeaders(h http.Header) []string {
+	result := make([]string, 0)
+	for _, value := range h.Values(HeaderVary) {
+		for _, item := range strings.Split(value, ",") {
+			item = strings.TrimSpace(item)
+			if item != "" {
+				result = append(result, textproto.CanonicalMIMEHeaderKey(item))
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func getVaryKey(key string, vary []string, h http.Header) string {
+	sb := new(strings.Builder)
+	sb.WriteString(key)
+	for _, name := range vary {
+		sb.WriteString("\n")
+		sb.WriteString(name)
+		sb.WriteString(":")
+		sb.WriteString(strings.Join(h.Values(name), ","))
+	}
+	return sb.String()
+}
+
+// NewCache returns a new http response cache middleware, it caches the response of GET and HEAD request,
+// the key is method + url + vary headers of response.
+// The response is cached only if the Cache-Control has max-age or s-maxage(s-maxage is preferred),
+// and is not cached if the Cache-Control has no-store or private, or the response has Set-Cookie.
+// The response of request with Authorization is cached and served only if the Cache-Control
+// has public, s-maxage or must-revalidate.
+// If the Cache-Control has stale-while-revalidate, the stale response will be returned
+// and the response will be revalidated in the background, only one revalidation for the same key at the same time.
+// The request with Cache-Control: no-cache will skip the cache and update it.
+func NewCache(config CacheConfig) elton.Handler {
+	store := config.Store
+	if store == nil {
+		store = NewLRUCacheStore(defaultCacheMaxEntries)
+	}
+	skipper := config.Skipper
+	if skipper == nil {
+		skipper = elton.DefaultSkipper
+	}
+	revalidateTimeout := config.RevalidateTimeout
+	if revalidateTimeout <= 0 {
+		revalidateTimeout = defaultCacheRevalidateTimeout
+	}
+	revalidating := sync.Map{}
+	revalidate := func(c *elton.Context, key string) {
+		e := c.Elton()
+		if e == nil {
+			return
+		}
+		// 同一个key只有一个后台更新
+		if _, loaded := revalidating.LoadOrStore(key, true); loaded {
+			return
+		}
+		// 请求结束后其context会被取消，因此后台更新使用新的context
+		ctx, cancel := gocontext.WithTimeout(gocontext.Background(), revalidateTimeout)
+		// 使用context标记后台更新，避免客户端通过请求头跳过缓存
+		req := c.Request.Clone(gocontext.WithValue(ctx, cacheRevalidateKey{}, true))
+		go func() {
+			defer cancel()
+			defer revalidating.Delete(key)
+			e.ServeHTTP(newNopResponseWriter(), req)
+		}()
+	}
+	return func(c *elton.Context) (err error) {
+		if skipper(c) {
+			return c.Next()
+		}
+		method := c.Request.Method
+		if method != http.MethodGet && method != http.MethodHead {
+			return c.Next()
+		}
+		key := method + " " + c.Request.URL.RequestURI()
+		reqHeader := c.Request.Header
+		isRevalidate := c.Context().Value(cacheRevalidateKey{}) != nil
+		hasAuthorization := reqHeader.Get(elton.HeaderAuthorization) != ""
+		skipCache := isRevalidate || parseCacheControl(reqHeader.Get(elton.HeaderCacheControl)).noCache
+
+		if !skipCache {
+			resp, _ := store.Get(key)
+			if resp != nil && len(resp.Vary) != 0 {
+				resp, _ = store.Get(getVaryKey(key, resp.Vary, reqHeader))
+			}
+			// 有认证信息的请求仅使用明确允许共享的缓存
+			if resp != nil && hasAuthorization &&
+				!parseCacheControl(resp.Header.Get(elton.HeaderCacheControl)).allowAuthorization() {
+				resp = nil
+			}
+			if resp != nil {
+				now := time.Now()
+				fresh := resp.Fresh(now)
+				if fresh || resp.Age(now) < resp.MaxAge+resp.StaleWhileRevalidate {
+					resp.applyTo(c)
+					c.SetHeader(HeaderAge, strconv.Itoa(int(resp.Age(now).Seconds())))
+					if fresh {
+						c.SetHeader(HeaderXCache, cacheHit)
+					} else {
+						c.SetHeader(HeaderXCache, cacheStale)
+						revalidate(c, key)
+					}
+					return nil
+				}
+			}
+		}
+
+		err = c.Next()
+		if err != nil {
+			return
+		}
+		if !isRevalidate {
+			c.SetHeader(HeaderXCache, cacheMiss)
+		}
+		if c.IsReaderBody() || !cacheableStatus[c.StatusCode] && c.StatusCode != 0 {
+			return
+		}
+		h := c.Header()
+		if h.Get(elton.HeaderSetCookie) != "" {
+			return
+		}
+		cc := parseCacheControl(h.Get(elton.HeaderCacheControl))
+		if cc.noStore || cc.private || cc.noCache {
+			return
+		}
+		if hasAuthorization && !cc.allowAuthorization() {
+			return
+		}
+		maxAge := cc.maxAge
+		if cc.sMaxAge >= 0 {
+			maxAge = cc.sMaxAge
+		}
+		if maxAge <= 0 {
+			return
+		}
+		vary := getVaryHeaders(h)
+		for _, name := range vary {
+			if name == "*" {
+				return
+			}
+		}
+		resp := newCachedResponse(c)
+		resp.Header.Del(HeaderXCache)
+		resp.CreatedAt = time.Now()
+		resp.MaxAge = time.Duration(maxAge) * time.Second
+		resp.StaleWhileRevalidate = time.Duration(cc.staleWhileRevalidate) * time.Second
+		ttl := resp.MaxAge + resp.StaleWhileRevalidate
+		if len(vary) != 0 {
+			// 记录vary，根据请求头获取对应的缓存
+			_ = store.Set(key, &CachedResponse{
+				Vary: vary,
+			}, ttl)
+			key = getVaryKey(key, vary, reqHeader)
+		}
+		e := store.Set(key, resp, ttl)
+		if e != nil && c.Elton() != nil {
+			c.Elton().EmitError(c, e)
+		}
+		return
+	}
+}
@@ -0,0 +1,334 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"bytes"
+	gocontext "context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vicanso/elton"
+)
+
+func TestParseCacheControl(t *testing.T) {
+	assert := assert.New(t)
+
+	cc := parseCacheControl("public, max-age=60, s-maxage=120, stale-while-revalidate=30")
+	assert.Equal(60, cc.maxAge)
+	assert.Equal(120, cc.sMaxAge)
+	assert.Equal(30, cc.staleWhileRevalidate)
+	assert.False(cc.noStore)
+	assert.True(cc.public)
+	assert.True(cc.allowAuthorization())
+	assert.False(parseCacheControl("max-age=60").allowAuthorization())
+	assert.True(parseCacheControl("must-revalidate, max-age=60").allowAuthorization())
+
+	cc = parseCacheControl("no-store, private, no-cache")
+	assert.True(cc.noStore)
+	assert.True(cc.private)
+	assert.True(cc.noCache)
+	assert.Equal(-1, cc.maxAge)
+	assert.Equal(-1, cc.sMaxAge)
+}
+
+func TestLRUCacheStore(t *testing.T) {
+	assert := assert.New(t)
+	clock := newFakeClock()
+	store := NewLRUCacheStore(2)
+	store.now = clock.Now
+
+	assert.Nil(store.Set("a", &CachedResponse{StatusCode: 200}, time.Second))
+	assert.Nil(store.Set("b", &CachedResponse{StatusCode: 201}, 2*time.Second))
+	resp, err := store.Get("a")
+	assert.Nil(err)
+	assert.Equal(200, resp.StatusCode)
+
+	// a最近使用，因此淘汰b
+	assert.Nil(store.Set("c", &CachedResponse{StatusCode: 202}, time.Second))
+	assert.Equal(2, store.Len())
+	resp, _ = store.Get("b")
+	assert.Nil(resp)
+
+	clock.Add(2 * time.Second)
+	resp, _ = store.Get("a")
+	assert.Nil(resp)
+	assert.Equal(1, store.Len())
+}
+
+func TestCache(t *testing.T) {
+	assert := assert.New(t)
+
+	newHandler := func(cacheControl string, calls *int32) elton.Handler {
+		return func(c *elton.Context) error {
+			atomic.AddInt32(calls, 1)
+			if cacheControl != "" {
+				c.SetHeader(elton.HeaderCacheControl, cacheControl)
+			}
+			c.BodyBuffer = bytes.NewBufferString("hello world")
+			return nil
+		}
+	}
+
+	t.Run("cache hit", func(t *testing.T) {
+		var calls int32
+		fn := NewCache(CacheConfig{})
+		for i := 0; i < 3; i++ {
+			c := elton.NewContext(httptest.NewRecorder(), httptest.NewRequest("GET", "/users?type=1", nil))
+			// 客户端无法通过请求头跳过缓存
+			c.Request.Header.Set("X-Cache-Revalidate", "1")
+			c.Next = func() error {
+				c.SetHeader("Cache-Control", "public, max-age=60")
+				return newHandler("", &calls)(c)
+			}
+			err := fn(c)
+			assert.Nil(err)
+			assert.Equal("hello world", c.BodyBuffer.String())
+			assert.Equal("public, max-age=60", c.GetHeader(elton.HeaderCacheControl))
+			if i == 0 {
+				assert.Equal(cacheMiss, c.GetHeader(HeaderXCache))
+			} else {
+				assert.Equal(cacheHit, c.GetHeader(HeaderXCache))
+				assert.Equal("0", c.GetHeader(HeaderAge))
+				assert.Equal(http.StatusOK, c.StatusCode)
+				// 修改body不影响缓存
+				c.BodyBuffer.WriteString("!")
+			}
+		}
+		assert.Equal(int32(1), calls)
+	})
+
+	t.Run("not cacheable", func(t *testing.T) {
+		for _, cacheControl := range []string{
+			"",
+			"no-store",
+			"private, max-age=60",
+			"max-age=0",
+		} {
+			var calls int32
+			fn := NewCache(CacheConfig{})
+			for i := 0; i < 2; i++ {
+				c := elton.NewContext(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+				c.Next = func() error {
+					return newHandler(cacheControl, &calls)(c)
+				}
+				assert.Nil(fn(c))
+			}
+			assert.Equal(int32(2), calls, cacheControl)
+		}
+
+		// post请求不缓存
+		var calls int32
+		fn := NewCache(CacheConfig{})
+		for i := 0; i < 2; i++ {
+			c := elton.NewContext(httptest.NewRecorder(), httptest.NewRequest("POST", "/", nil))
+			c.Next = func() error {
+				c.SetHeader(elton.HeaderCacheControl, "max-age=60")
+				return newHandler("", &calls)(c)
+			}
+			assert.Nil(fn(c))
+		}
+		assert.Equal(int32(2), calls)
+	})
+
+	t.Run("s-maxage", func(t *testing.T) {
+		store := NewLRUCacheStore(10)
+		fn := NewCache(CacheConfig{
+			Store: store,
+		})
+		c := elton.NewContext(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		c.Next = func() error {
+			c.SetHeader(elton.HeaderCacheControl, "max-age=10, s-maxage=100")
+			return nil
+		}
+		assert.Nil(fn(c))
+		resp, _ := store.Get("GET /")
+		assert.Equal(100*time.Second, resp.MaxAge)
+	})
+
+	t.Run("request no-cache", func(t *testing.T) {
+		var calls int32
+		fn := NewCache(CacheConfig{})
+		for i := 0; i < 2; i++ {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set(elton.HeaderCacheControl, "no-cache")
+			c := elton.NewContext(httptest.NewRecorder(), req)
+			c.Next = func() error {
+				c.SetHeader(elton.HeaderCacheControl, "max-age=60")
+				return newHandler("", &calls)(c)
+			}
+			assert.Nil(fn(c))
+		}
+		assert.Equal(int32(2), calls)
+	})
+
+	t.Run("vary", func(t *testing.T) {
+		var calls int32
+		fn := NewCache(CacheConfig{})
+		for _, encoding := range []string{"gzip", "br", "gzip", "br"} {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set(elton.HeaderAcceptEncoding, encoding)
+			c := elton.NewContext(httptest.NewRecorder(), req)
+			c.Next = func() error {
+				atomic.AddInt32(&calls, 1)
+				c.SetHeader(elton.HeaderCacheControl, "max-age=60")
+				c.SetHeader(HeaderVary, "accept-encoding")
+				c.BodyBuffer = bytes.NewBufferString(encoding)
+				return nil
+			}
+			assert.Nil(fn(c))
+			assert.Equal(encoding, c.BodyBuffer.String())
+		}
+		assert.Equal(int32(2), calls)
+	})
+
+	t.Run("authorization", func(t *testing.T) {
+		for _, tt := range []struct {
+			cacheControl string
+			calls        int32
+		}{
+			// 未明确允许共享的不缓存
+			{
+				cacheControl: "max-age=60",
+				calls:        3,
+			},
+			{
+				cacheControl: "public, max-age=60",
+				calls:        1,
+			},
+			{
+				cacheControl: "s-maxage=60",
+				calls:        1,
+			},
+			{
+				cacheControl: "must-revalidate, max-age=60",
+				calls:        1,
+			},
+		} {
+			var calls int32
+			fn := NewCache(CacheConfig{})
+			for _, user := range []string{"alice", "bob", "alice"} {
+				req := httptest.NewRequest("GET", "/me", nil)
+				req.Header.Set(elton.HeaderAuthorization, user)
+				c := elton.NewContext(httptest.NewRecorder(), req)
+				c.Next = func() error {
+					atomic.AddInt32(&calls, 1)
+					c.SetHeader(elton.HeaderCacheControl, tt.cacheControl)
+					c.BodyBuffer = bytes.NewBufferString("user:" + user)
+					return nil
+				}
+				assert.Nil(fn(c))
+				if tt.calls != 1 {
+					assert.Equal("user:"+user, c.BodyBuffer.String())
+				}
+			}
+			assert.Equal(tt.calls, calls)
+		}
+
+		// 无认证信息的请求缓存，有认证信息的请求不使用
+		var calls int32
+		fn := NewCache(CacheConfig{})
+		for _, user := range []string{"", "bob"} {
+			req := httptest.NewRequest("GET", "/me", nil)
+			if user != "" {
+				req.Header.Set(elton.HeaderAuthorization, user)
+			}
+			c := elton.NewContext(httptest.NewRecorder(), req)
+			c.Next = func() error {
+				atomic.AddInt32(&calls, 1)
+				c.SetHeader(elton.HeaderCacheControl, "max-age=60")
+				c.BodyBuffer = bytes.NewBufferString("user:" + user)
+				return nil
+			}
+			assert.Nil(fn(c))
+			assert.Equal("user:"+user, c.BodyBuffer.String())
+		}
+		assert.Equal(int32(2), calls)
+	})
+}
+
+func TestCacheStaleWhileRevalidate(t *testing.T) {
+	assert := assert.New(t)
+	store := NewLRUCacheStore(10)
+	var calls int32
+	done := make(chan bool)
+	start := make(chan bool)
+	var revalidateErr error
+	e := elton.New()
+	e.Use(NewCache(CacheConfig{
+		Store: store,
+	}))
+	e.GET("/", func(c *elton.Context) error {
+		count := atomic.AddInt32(&calls, 1)
+		c.SetHeader(elton.HeaderCacheControl, "max-age=60, stale-while-revalidate=60")
+		if count == 2 {
+			// 等待原请求结束后再更新
+			<-start
+			revalidateErr = c.Context().Err()
+			c.BodyBuffer = bytes.NewBufferString("new")
+			defer func() {
+				done <- true
+			}()
+			return nil
+		}
+		c.BodyBuffer = bytes.NewBufferString("old")
+		return nil
+	})
+
+	resp := httptest.NewRecorder()
+	e.ServeHTTP(resp, httptest.NewRequest("GET", "/", nil))
+	assert.Equal("old", resp.Body.String())
+
+	// 将缓存设置为过期
+	cached, _ := store.Get("GET /")
+	cached.CreatedAt = cached.CreatedAt.Add(-90 * time.Second)
+
+	resp = httptest.NewRecorder()
+	ctx, cancel := gocontext.WithCancel(gocontext.Background())
+	e.ServeHTTP(resp, httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+	assert.Equal("old", resp.Body.String())
+	assert.Equal(cacheStale, resp.Header().Get(HeaderXCache))
+	// 原请求结束后context被取消，不影响后台更新
+	cancel()
+	close(start)
+	<-done
+	assert.Nil(revalidateErr)
+	// 等待缓存更新
+	for i := 0; i < 100; i++ {
+		cached, _ = store.Get("GET /")
+		if string(cached.Body) == "new" {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	resp = httptest.NewRecorder()
+	e.ServeHTTP(resp, httptest.NewRequest("GET", "/", nil))
+	assert.Equal("new", resp.Body.String())
+	assert.Equal(cacheHit, resp.Header().Get(HeaderXCache))
+	assert.Equal(int32(2), atomic.LoadInt32(&calls))
+}
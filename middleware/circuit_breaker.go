@@ -0,0 +1,343 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/vicanso/elton"
+	"github.com/vicanso/hes"
+)
+
+const (
+	// ErrCircuitBreakerCategory circuit breaker error category
+	ErrCircuitBreakerCategory = "elton-circuit-breaker"
+
+	defaultCircuitBreakerWindow      = 10 * time.Second
+	defaultCircuitBreakerMinRequests = 20
+	defaultCircuitBreakerErrorRate   = 0.5
+	defaultCircuitBreakerOpenTimeout = 5 * time.Second
+)
+
+const (
+	// CircuitClosed closed state, all requests are allowed
+	CircuitClosed CircuitState = iota
+	// CircuitOpen open state, all requests are rejected
+	CircuitOpen
+	// CircuitHalfOpen half open state, only a few requests are allowed to probe
+	CircuitHalfOpen
+)
+
+var (
+	// ErrCircuitOpen circuit is open
+	ErrCircuitOpen = &hes.Error{
+		StatusCode: http.StatusServiceUnavailable,
+		Message:    "service is unavailable, circuit is open",
+		Category:   ErrCircuitBreakerCategory,
+	}
+)
+
+type (
+	// CircuitState state of circuit
+	CircuitState int
+	// CircuitStateListener state change listener
+	CircuitStateListener func(key string, from CircuitState, to CircuitState)
+	// CircuitBreakerConfig circuit breaker config
+	CircuitBreakerConfig struct {
+		// Key returns the key of circuit, default is "method route"
+		Key func(c *elton.Context) string
+		// Window the window of statistics, default is 10 seconds
+		Window time.Duration
+		// MinRequests min requests of window to trip the circuit, default is 20
+		MinRequests int
+		// ErrorRate the circuit will be open if the failure rate >= error rate, default is 0.5
+		ErrorRate float64
+		// SlowThreshold the request is treated as failure if the latency is greater than it, zero means disable
+		SlowThreshold time.Duration
+		// OpenTimeout the duration of open state, the circuit will be half open after it, default is 5 seconds
+		OpenTimeout time.Duration
+		// HalfOpenRequests the max probe requests of half open state, default is 1
+		HalfOpenRequests int
+		// IsFailure check the error is failure, default is server error(5xx)
+		IsFailure func(err error) bool
+		// Error the error of open state, default is ErrCircuitOpen
+		Error *hes.Error
+		// OnStateChange state change listener
+		OnStateChange CircuitStateListener
+		Skipper       elton.Skipper
+	}
+	circuit struct {
+		mutex       sync.Mutex
+		state       CircuitState
+		windowStart time.Time
+		total       int
+		failures    int
+		openedAt    time.Time
+		probing     int
+		probeOK     int
+		// generation is increased when the state changes
+		generation uint64
+	}
+	// CircuitTicket the ticket of allowed request, it should be passed to Done.
+	// The result of ticket is ignored if the state of circuit has been changed.
+	CircuitTicket struct {
+		key        string
+		state      CircuitState
+		generation uint64
+	}
+	// CircuitBreaker circuit breaker
+	CircuitBreaker struct {
+		mutex    sync.Mutex
+		circuits map[string]*circuit
+		config   CircuitBreakerConfig
+		now      func() time.Time
+	}
+)
+
+// String returns the name of state
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// NewCircuitBreaker returns a new circuit breaker
+func NewCircuitBreaker(config CircuitBreakerConfig) *CircuitBreaker {
+	if config.Window <= 0 {
+		config.Window = defaultCircuitBreakerWindow
+	}
+	if config.MinRequests <= 0 {
+		config.MinRequests = defaultCircuitBreakerMinRequests
+	}
+	if config.ErrorRate <= 0 {
+		config.ErrorRate = defaultCircuitBreakerErrorRate
+	}
+	if config.OpenTimeout <= 0 {
+		config.OpenTimeout = defaultCircuitBreakerOpenTimeout
+	}
+	if config.HalfOpenRequests <= 0 {
+		config.HalfOpenRequests = 1
+	}
+	if config.IsFailure == nil {
+		config.IsFailure = isServerError
+	}
+	if config.Error == nil {
+		config.Error = ErrCircuitOpen
+	}
+	return &CircuitBreaker{
+		circuits: make(map[string]*circuit),
+		config:   config,
+		now:      time.Now,
+	}
+}
+
+func (cb *CircuitBreaker) getCircuit(key string) *circuit {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	ci, ok := cb.circuits[key]
+	if !ok {
+		ci = &circuit{
+			windowStart: cb.now(),
+		}
+		cb.circuits[key] = ci
+	}
+	return ci
+}
+
+// setState sets the state of circuit, it should be called with lock.
+// It returns the notify function of state change, which should be called after unlock.
+func (cb *CircuitBreaker) setState(key string, ci *circuit, state CircuitState, now time.Time) func() {
+	from := ci.state
+	if from == state {
+		return nil
+	}
+	ci.state = state
+	ci.generation++
+	ci.total = 0
+	ci.failures = 0
+	ci.probing = 0
+	ci.probeOK = 0
+	ci.windowStart = now
+	if state == CircuitOpen {
+		ci.openedAt = now
+	}
+	ln := cb.config.OnStateChange
+	if ln == nil {
+		return nil
+	}
+	return func() {
+		ln(key, from, state)
+	}
+}
+
+func notifyStateChange(notify func()) {
+	if notify != nil {
+		notify()
+	}
+}
+
+// State returns the state of circuit
+func (cb *CircuitBreaker) State(key string) CircuitState {
+	ci := cb.getCircuit(key)
+	ci.mutex.Lock()
+	var notify func()
+	now := cb.now()
+	if ci.state == CircuitOpen && now.Sub(ci.openedAt) >= cb.config.OpenTimeout {
+		notify = cb.setState(key, ci, CircuitHalfOpen, now)
+	}
+	state := ci.state
+	ci.mutex.Unlock()
+	notifyStateChange(notify)
+	return state
+}
+
+// Allow checks the request of key is allowed,
+// the ticket should be passed to Done after the request is finished.
+func (cb *CircuitBreaker) Allow(key string) (ticket CircuitTicket, allowed bool) {
+	ci := cb.getCircuit(key)
+	ci.mutex.Lock()
+	var notify func()
+	defer func() {
+		ci.mutex.Unlock()
+		notifyStateChange(notify)
+	}()
+	now := cb.now()
+	switch ci.state {
+	case CircuitOpen:
+		if now.Sub(ci.openedAt) < cb.config.OpenTimeout {
+			return
+		}
+		notify = cb.setState(key, ci, CircuitHalfOpen, now)
+		fallthrough
+	case CircuitHalfOpen:
+		if ci.probing >= cb.config.HalfOpenRequests {
+			return
+		}
+		ci.probing++
+	}
+	ticket = CircuitTicket{
+		key:        key,
+		state:      ci.state,
+		generation: ci.generation,
+	}
+	return ticket, true
+}
+
+// Done records the result of request of ticket
+func (cb *CircuitBreaker) Done(ticket CircuitTicket, latency time.Duration, err error) {
+	conf := cb.config
+	failure := conf.IsFailure(err) || (conf.SlowThreshold > 0 && latency > conf.SlowThreshold)
+	cb.done(ticket, failure)
+}
+
+func (cb *CircuitBreaker) done(ticket CircuitTicket, failure bool) {
+	conf := cb.config
+	key := ticket.key
+	ci := cb.getCircuit(key)
+	ci.mutex.Lock()
+	var notify func()
+	defer func() {
+		ci.mutex.Unlock()
+		notifyStateChange(notify)
+	}()
+	// 状态已变化，忽略之前状态的请求结果
+	if ticket.generation != ci.generation {
+		return
+	}
+	now := cb.now()
+	switch ticket.state {
+	case CircuitHalfOpen:
+		if failure {
+			notify = cb.setState(key, ci, CircuitOpen, now)
+			return
+		}
+		ci.probeOK++
+		// 所有探测请求都成功则关闭
+		if ci.probeOK >= conf.HalfOpenRequests {
+			notify = cb.setState(key, ci, CircuitClosed, now)
+		}
+	case CircuitClosed:
+		if now.Sub(ci.windowStart) >= conf.Window {
+			ci.windowStart = now
+			ci.total = 0
+			ci.failures = 0
+		}
+		ci.total++
+		if failure {
+			ci.failures++
+		}
+		if ci.total >= conf.MinRequests &&
+			float64(ci.failures)/float64(ci.total) >= conf.ErrorRate {
+			notify = cb.setState(key, ci, CircuitOpen, now)
+		}
+	}
+}
+
+// Handler returns the circuit breaker middleware,
+// it will return the error of config if the circuit is open.
+func (cb *CircuitBreaker) Handler() elton.Handler {
+	skipper := cb.config.Skipper
+	if skipper == nil {
+		skipper = elton.DefaultSkipper
+	}
+	getKey := cb.config.Key
+	if getKey == nil {
+		getKey = func(c *elton.Context) string {
+			return c.Request.Method + " " + c.Route
+		}
+	}
+	return func(c *elton.Context) (err error) {
+		if skipper(c) {
+			return c.Next()
+		}
+		ticket, allowed := cb.Allow(getKey(c))
+		if !allowed {
+			err = cb.config.Error
+			return
+		}
+		startedAt := time.Now()
+		finished := false
+		defer func() {
+			// panic则认为失败，避免半开状态的探测请求一直未完成
+			if !finished {
+				cb.done(ticket, true)
+			}
+		}()
+		err = c.Next()
+		finished = true
+		cb.Done(ticket, time.Since(startedAt), err)
+		return
+	}
+}
+
+// NewCircuitBreakerHandler returns a new circuit breaker middleware
+func NewCircuitBreakerHandler(config CircuitBreakerConfig) elton.Handler {
+	return NewCircuitBreaker(config).Handler()
+}
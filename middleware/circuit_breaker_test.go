@@ -0,0 +1,182 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vicanso/elton"
+	"github.com/vicanso/hes"
+)
+
+func TestCircuitState(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("closed", CircuitClosed.String())
+	assert.Equal("open", CircuitOpen.String())
+	assert.Equal("half-open", CircuitHalfOpen.String())
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	assert := assert.New(t)
+	clock := newFakeClock()
+	changes := make([]string, 0)
+	cb := NewCircuitBreaker(CircuitBreakerConfig{
+		MinRequests:   4,
+		ErrorRate:     0.5,
+		SlowThreshold: time.Second,
+		OpenTimeout:   time.Minute,
+		OnStateChange: func(key string, from, to CircuitState) {
+			changes = append(changes, key+":"+from.String()+"->"+to.String())
+		},
+	})
+	cb.now = clock.Now
+	key := "GET /users/me"
+	serverErr := errors.New("server error")
+
+	allow := func() CircuitTicket {
+		ticket, allowed := cb.Allow(key)
+		assert.True(allowed)
+		return ticket
+	}
+
+	cb.Done(allow(), time.Millisecond, nil)
+	// 客户端出错不认为失败
+	cb.Done(allow(), time.Millisecond, hes.New("invalid params"))
+	cb.Done(allow(), time.Millisecond, serverErr)
+	assert.Equal(CircuitClosed, cb.State(key))
+	lateTicket := allow()
+	// 请求过慢认为失败
+	cb.Done(allow(), 2*time.Second, nil)
+	assert.Equal(CircuitOpen, cb.State(key))
+	_, allowed := cb.Allow(key)
+	assert.False(allowed)
+
+	// 超时后转换为半开，只允许一个探测请求
+	clock.Add(time.Minute)
+	probeTicket := allow()
+	_, allowed = cb.Allow(key)
+	assert.False(allowed)
+	assert.Equal(CircuitHalfOpen, cb.State(key))
+	// 关闭状态时的请求完成较晚，不影响半开状态的探测
+	cb.Done(lateTicket, time.Millisecond, nil)
+	assert.Equal(CircuitHalfOpen, cb.State(key))
+	// 探测失败重新打开
+	cb.Done(probeTicket, time.Millisecond, serverErr)
+	assert.Equal(CircuitOpen, cb.State(key))
+	// 重复的结果忽略
+	cb.Done(probeTicket, time.Millisecond, nil)
+	assert.Equal(CircuitOpen, cb.State(key))
+
+	clock.Add(time.Minute)
+	cb.Done(allow(), time.Millisecond, nil)
+	assert.Equal(CircuitClosed, cb.State(key))
+
+	// 统计窗口过期后重新计算
+	cb.Done(allow(), time.Millisecond, serverErr)
+	cb.Done(allow(), time.Millisecond, serverErr)
+	clock.Add(time.Minute)
+	cb.Done(allow(), time.Millisecond, serverErr)
+	cb.Done(allow(), time.Millisecond, nil)
+	assert.Equal(CircuitClosed, cb.State(key))
+
+	assert.Equal([]string{
+		"GET /users/me:closed->open",
+		"GET /users/me:open->half-open",
+		"GET /users/me:half-open->open",
+		"GET /users/me:open->half-open",
+		"GET /users/me:half-open->closed",
+	}, changes)
+}
+
+func TestCircuitBreakerHandler(t *testing.T) {
+	assert := assert.New(t)
+	customErr := &hes.Error{
+		StatusCode: 503,
+		Message:    "user service is unavailable",
+	}
+	fn := NewCircuitBreakerHandler(CircuitBreakerConfig{
+		MinRequests: 1,
+		Error:       customErr,
+		Key: func(c *elton.Context) string {
+			return "user-service"
+		},
+	})
+	serverErr := errors.New("server error")
+	newContext := func() *elton.Context {
+		c := elton.NewContext(nil, httptest.NewRequest("GET", "/", nil))
+		c.Next = func() error {
+			return serverErr
+		}
+		return c
+	}
+	assert.Equal(serverErr, fn(newContext()))
+	assert.Equal(customErr, fn(newContext()))
+
+	c := newContext()
+	c.Committed = true
+	assert.Equal(serverErr, fn(c))
+}
+
+func TestCircuitBreakerHandlerPanic(t *testing.T) {
+	assert := assert.New(t)
+	clock := newFakeClock()
+	cb := NewCircuitBreaker(CircuitBreakerConfig{
+		MinRequests: 1,
+		OpenTimeout: time.Minute,
+		Key: func(c *elton.Context) string {
+			return "user-service"
+		},
+	})
+	cb.now = clock.Now
+	fn := cb.Handler()
+	newContext := func(next func() error) *elton.Context {
+		c := elton.NewContext(nil, httptest.NewRequest("GET", "/", nil))
+		c.Next = next
+		return c
+	}
+	serverErr := errors.New("server error")
+	assert.Equal(serverErr, fn(newContext(func() error {
+		return serverErr
+	})))
+	assert.Equal(CircuitOpen, cb.State("user-service"))
+
+	// 探测请求panic则重新打开
+	clock.Add(time.Minute)
+	assert.Panics(func() {
+		_ = fn(newContext(func() error {
+			panic("abc")
+		}))
+	})
+	assert.Equal(CircuitOpen, cb.State("user-service"))
+
+	// 超时后可以再次探测
+	clock.Add(time.Minute)
+	assert.Nil(fn(newContext(func() error {
+		return nil
+	})))
+	assert.Equal(CircuitClosed, cb.State("user-service"))
+}
@@ -26,6 +26,7 @@ import (
 	"bytes"
 	"errors"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/vicanso/elton"
@@ -62,15 +63,67 @@ type (
 	}
 )
 
-// AcceptEncoding check request accept encoding
+// getAcceptEncodingQuality returns the q-value of encoding,
+// the q-value of "*" will be used if the encoding is not specified
+func getAcceptEncodingQuality(acceptEncoding, encoding string) float64 {
+	quality := 0.0
+	found := false
+	for _, item := range strings.Split(acceptEncoding, ",") {
+		arr := strings.Split(item, ";")
+		name := strings.ToLower(strings.TrimSpace(arr[0]))
+		if name != encoding && name != "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range arr[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				v, err := strconv.ParseFloat(param[2:], 64)
+				if err == nil {
+					q = v
+				}
+			}
+		}
+		// 明确指定的encoding优先于*
+		if name == encoding {
+			return q
+		}
+		if !found {
+			quality = q
+			found = true
+		}
+	}
+	return quality
+}
+
+// AcceptEncoding check request accept encoding, it's not acceptable if the q-value is 0
 func AcceptEncoding(c *elton.Context, encoding string) (bool, string) {
-	acceptEncoding := c.GetRequestHeader(elton.HeaderAcceptEncoding)
-	if strings.Contains(acceptEncoding, encoding) {
+	if AcceptEncodingQuality(c, encoding) > 0 {
 		return true, encoding
 	}
 	return false, ""
 }
 
+// AcceptEncodingQuality returns the q-value of encoding from request header Accept-Encoding,
+// it returns 0 if the encoding is not acceptable
+func AcceptEncodingQuality(c *elton.Context, encoding string) float64 {
+	acceptEncoding := c.GetRequestHeader(elton.HeaderAcceptEncoding)
+	if acceptEncoding == "" {
+		return 0
+	}
+	return getAcceptEncodingQuality(acceptEncoding, encoding)
+}
+
+// addVary adds the name to the header Vary if it's not exists
+func addVary(c *elton.Context, name string) {
+	for _, item := range getVaryHeaders(c.Header()) {
+		if item == name || item == "*" {
+			return
+		}
+	}
+	c.AddHeader(HeaderVary, name)
+}
+
 // AddCompressor to the compress config
 func (conf *CompressConfig) AddCompressor(compressor Compressor) {
 	if conf.Compressors == nil {
@@ -96,6 +149,8 @@ func NewDefaultCompress() elton.Handler {
 
 // NewCompress return a new compress middleware.
 // It will use 'text|javascript|json|wasm|font' as default content type checker for compress.
+// The compressor with the highest q-value of Accept-Encoding will be used,
+// and the header Vary: Accept-Encoding will be set for compressible response.
 // It will throw a panic if the compressors is empty.
 func NewCompress(config CompressConfig) elton.Handler {
 	skipper := config.Skipper
@@ -133,6 +188,8 @@ func NewCompress(config CompressConfig) elton.Handler {
 		if !checker.MatchString(contentType) {
 			return
 		}
+		// 可压缩的响应均设置Vary，避免缓存服务器返回错误的数据
+		addVary(c, elton.HeaderAcceptEncoding)
 
 		var body []byte
 		if c.BodyBuffer != nil {
@@ -147,7 +204,6 @@ func NewCompress(config CompressConfig) elton.Handler {
 
 		fillHeader := func(encoding string) {
 			c.SetHeader(elton.HeaderContentEncoding, encoding)
-			c.AddHeader("Vary", "Accept-Encoding")
 			etagValue := c.GetHeader(elton.HeaderETag)
 			// after compress, etag should be weak etag
 			if etagValue != "" && !strings.HasPrefix(etagValue, "W/") {
@@ -155,39 +211,53 @@ func NewCompress(config CompressConfig) elton.Handler {
 			}
 		}
 
-		for _, compressor := range compressorList {
-			acceptable, encoding := compressor.Accept(c, bodySize)
+		// 选择q-value最高的压缩方式，相同时按配置的顺序
+		var compressor Compressor
+		encoding := ""
+		quality := 0.0
+		for _, item := range compressorList {
+			acceptable, itemEncoding := item.Accept(c, bodySize)
 			if !acceptable {
 				continue
 			}
-			if isReaderBody {
-				// 压缩时清除content length
-				c.Header().Del(elton.HeaderContentLength)
-				// 执行pipe之前先设置http响应头
-				fillHeader(encoding)
-				err = compressor.Pipe(c)
-				// 如果出错直接返回，此时也有可能已经开始写入数据，导致http后续无法再写入status code
-				if err != nil {
-					return
-				}
-				// 成功跳出循环
-				// pipe 将数据直接转至原有的Response，因此设置committed为true
-				c.Committed = true
-				// 清除 response body
-				c.Body = nil
-				break
+			q := AcceptEncodingQuality(c, itemEncoding)
+			if compressor == nil || q > quality {
+				compressor = item
+				encoding = itemEncoding
+				quality = q
 			}
+		}
+		if compressor == nil {
+			return
+		}
+		if isReaderBody {
+			// 压缩时清除content length
+			c.Header().Del(elton.HeaderContentLength)
+			// 执行pipe之前先设置http响应头
+			fillHeader(encoding)
+			err = compressor.Pipe(c)
+			// 如果出错直接返回，此时也有可能已经开始写入数据，导致http后续无法再写入status code
+			if err != nil {
+				return
+			}
+			// pipe 将数据直接转至原有的Response，因此设置committed为true
+			c.Committed = true
+			// 清除 response body
+			c.Body = nil
+			return
+		}
 
-			newBuf, e := compressor.Compress(body)
-			// 如果压缩成功，则使用压缩数据
-			// 失败则忽略
-			if e != nil {
+		newBuf, e := compressor.Compress(body)
+		// 如果压缩成功，则使用压缩数据
+		// 失败则忽略
+		if e != nil {
+			if c.Elton() != nil {
 				c.Elton().EmitError(c, e)
-			} else {
-				fillHeader(encoding)
-				c.BodyBuffer = newBuf
 			}
+			return
 		}
+		fillHeader(encoding)
+		c.BodyBuffer = newBuf
 		return
 	}
 }
@@ -72,6 +72,70 @@ func TestAcceptEncoding(t *testing.T) {
 	acceptable, encoding = AcceptEncoding(c, elton.Gzip)
 	assert.True(acceptable)
 	assert.Equal(elton.Gzip, encoding)
+
+	// 不再是包含判断
+	c.SetRequestHeader(elton.HeaderAcceptEncoding, "x-gzip")
+	acceptable, _ = AcceptEncoding(c, elton.Gzip)
+	assert.False(acceptable)
+
+	c.SetRequestHeader(elton.HeaderAcceptEncoding, "gzip;q=0, br")
+	acceptable, _ = AcceptEncoding(c, elton.Gzip)
+	assert.False(acceptable)
+	acceptable, _ = AcceptEncoding(c, elton.Br)
+	assert.True(acceptable)
+
+	c.SetRequestHeader(elton.HeaderAcceptEncoding, "br;q=0.5, *;q=0.8, zstd;q=0")
+	assert.Equal(0.5, AcceptEncodingQuality(c, elton.Br))
+	assert.Equal(0.8, AcceptEncodingQuality(c, elton.Gzip))
+	assert.Equal(0.0, AcceptEncodingQuality(c, ZstdEncoding))
+}
+
+func TestCompressSelectEncoding(t *testing.T) {
+	assert := assert.New(t)
+	htmlData := "<html><body>" + randomString(8192) + "</body></html>"
+	fn := NewCompress(NewCompressConfig(
+		new(GzipCompressor),
+		new(BrCompressor),
+		new(ZstdCompressor),
+	))
+	tests := []struct {
+		acceptEncoding string
+		encoding       string
+	}{
+		{
+			acceptEncoding: "",
+		},
+		{
+			acceptEncoding: "gzip, deflate, br",
+			encoding:       GzipEncoding,
+		},
+		{
+			acceptEncoding: "gzip;q=0.8, br",
+			encoding:       BrEncoding,
+		},
+		{
+			acceptEncoding: "gzip;q=0.8, br;q=0.9, zstd",
+			encoding:       ZstdEncoding,
+		},
+		{
+			acceptEncoding: "gzip;q=0, *;q=0.5",
+			encoding:       BrEncoding,
+		},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(elton.HeaderAcceptEncoding, tt.acceptEncoding)
+		c := elton.NewContext(httptest.NewRecorder(), req)
+		c.SetHeader(elton.HeaderContentType, "text/html")
+		c.Next = func() error {
+			c.BodyBuffer = bytes.NewBufferString(htmlData)
+			return nil
+		}
+		assert.Nil(fn(c))
+		assert.Equal(tt.encoding, c.GetHeader(elton.HeaderContentEncoding), tt.acceptEncoding)
+		// vary 总是设置且不重复
+		assert.Equal([]string{elton.HeaderAcceptEncoding}, c.Header().Values(HeaderVary))
+	}
 }
 
 func TestNewCompressConfig(t *testing.T) {
@@ -0,0 +1,306 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/vicanso/elton"
+)
+
+type (
+	// CompressWriter compress writer, it can be reset and reused
+	CompressWriter interface {
+		io.WriteCloser
+		// Flush flushes the pending compressed data to the underlying writer
+		Flush() error
+		// Reset resets the writer to write to w
+		Reset(w io.Writer)
+	}
+	// StreamCompressor the compressor supports streaming compression
+	StreamCompressor interface {
+		Compressor
+		// Writer returns a compress writer(from pool) which writes to w
+		Writer(w io.Writer) CompressWriter
+		// Release puts the compress writer back to pool
+		Release(w CompressWriter)
+	}
+	// compressResponseWriter wraps the http response writer,
+	// it decides whether to compress when the first data is written.
+	compressResponseWriter struct {
+		http.ResponseWriter
+		c          *elton.Context
+		checker    *regexp.Regexp
+		compressor StreamCompressor
+		encoding   string
+		// 压缩的writer，为nil表示不压缩
+		writer     CompressWriter
+		statusCode int
+		decided    bool
+		// 未确认是否压缩时缓存的数据
+		buf []byte
+	}
+)
+
+var errCompressWriterClosed = errors.New("compress writer is closed")
+
+func newCompressResponseWriter(c *elton.Context, checker *regexp.Regexp, compressor StreamCompressor, encoding string) *compressResponseWriter {
+	return &compressResponseWriter{
+		ResponseWriter: c.Response,
+		c:              c,
+		checker:        checker,
+		compressor:     compressor,
+		encoding:       encoding,
+	}
+}
+
+// WriteHeader sets the status code, it will be written after decided
+func (w *compressResponseWriter) WriteHeader(statusCode int) {
+	if w.decided {
+		return
+	}
+	w.statusCode = statusCode
+}
+
+// Write writes the data, the data is buffered until it's over the min length of compressor
+func (w *compressResponseWriter) Write(p []byte) (int, error) {
+	if w.decided {
+		if w.writer != nil {
+			return w.writer.Write(p)
+		}
+		return w.ResponseWriter.Write(p)
+	}
+	w.buf = append(w.buf, p...)
+	contentLength := w.Header().Get(elton.HeaderContentLength)
+	// 已知长度或数据已大于最小压缩长度，则可判断是否压缩
+	if contentLength != "" {
+		size, _ := strconv.Atoi(contentLength)
+		return len(p), w.decide(size)
+	}
+	if acceptable, _ := w.compressor.Accept(w.c, len(w.buf)); acceptable {
+		return len(p), w.decide(len(w.buf))
+	}
+	return len(p), nil
+}
+
+// decide decides whether to compress and writes the buffered data,
+// the size is -1 if the length is unknown
+func (w *compressResponseWriter) decide(size int) error {
+	w.decided = true
+	if w.shouldCompress(size) {
+		h := w.Header()
+		h.Del(elton.HeaderContentLength)
+		h.Set(elton.HeaderContentEncoding, w.encoding)
+		etagValue := h.Get(elton.HeaderETag)
+		// after compress, etag should be weak etag
+		if etagValue != "" && !strings.HasPrefix(etagValue, "W/") {
+			h.Set(elton.HeaderETag, "W/"+etagValue)
+		}
+		w.writer = w.compressor.Writer(w.ResponseWriter)
+	}
+	if w.statusCode != 0 {
+		w.ResponseWriter.WriteHeader(w.statusCode)
+	}
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+func (w *compressResponseWriter) shouldCompress(size int) bool {
+	h := w.Header()
+	if h.Get(elton.HeaderContentEncoding) != "" ||
+		w.c.Request.Method == http.MethodHead ||
+		w.statusCode == http.StatusNoContent ||
+		w.statusCode == http.StatusNotModified {
+		return false
+	}
+	contentType := h.Get(elton.HeaderContentType)
+	// 未设置数据类型，则根据首部数据判断
+	if contentType == "" && len(w.buf) != 0 {
+		contentType = http.DetectContentType(w.buf)
+		h.Set(elton.HeaderContentType, contentType)
+	}
+	if !w.checker.MatchString(contentType) {
+		return false
+	}
+	// 可压缩的响应均设置Vary
+	addVary(w.c, elton.HeaderAcceptEncoding)
+	acceptable, _ := w.compressor.Accept(w.c, size)
+	return acceptable
+}
+
+// Flush flushes the buffered data, it's used for sse or chunked response
+func (w *compressResponseWriter) Flush() {
+	if !w.decided {
+		// 长度未知，根据数据类型判断是否压缩
+		_ = w.decide(-1)
+	}
+	if w.writer != nil {
+		_ = w.writer.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack hijacks the connection
+func (w *compressResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer is not hijacker")
+	}
+	return hijacker.Hijack()
+}
+
+// Push the target to http response
+func (w *compressResponseWriter) Push(target string, opts *http.PushOptions) error {
+	pusher, ok := w.ResponseWriter.(http.Pusher)
+	if !ok {
+		return elton.ErrNotSupportPush
+	}
+	return pusher.Push(target, opts)
+}
+
+// Close writes the buffered data and closes the compress writer
+func (w *compressResponseWriter) Close() (err error) {
+	if w.compressor == nil {
+		return errCompressWriterClosed
+	}
+	if !w.decided {
+		// 所有数据均已写入，长度为缓存数据的长度
+		err = w.decide(len(w.buf))
+	}
+	if w.writer != nil {
+		e := w.writer.Close()
+		if err == nil {
+			err = e
+		}
+		w.compressor.Release(w.writer)
+		w.writer = nil
+	}
+	w.compressor = nil
+	return
+}
+
+// NewStreamCompress returns a new streaming compress middleware, it wraps the response as a compress writer,
+// so the data written to response(such as sse or reader body) is compressed as stream.
+// The compressor should implement StreamCompressor, and the one with the highest q-value of Accept-Encoding will be used.
+// Whether to compress is decided by the content type(it will be detected from the first bytes if not set) and
+// the length of data(the first bytes will be buffered until it's over the min length of compressor, or Flush is called).
+// It should be added after the middlewares which modify the response(such as responder),
+// because the response is written by this middleware.
+// It will throw a panic if there is no stream compressor.
+func NewStreamCompress(config CompressConfig) elton.Handler {
+	skipper := config.Skipper
+	if skipper == nil {
+		skipper = elton.DefaultSkipper
+	}
+	checker := config.Checker
+	if checker == nil {
+		checker = DefaultCompressRegexp
+	}
+	compressorList := make([]StreamCompressor, 0, len(config.Compressors))
+	for _, item := range config.Compressors {
+		if compressor, ok := item.(StreamCompressor); ok {
+			compressorList = append(compressorList, compressor)
+		}
+	}
+	if len(compressorList) == 0 {
+		panic(errors.New("stream compressor can't be empty"))
+	}
+	return func(c *elton.Context) (err error) {
+		if skipper(c) {
+			return c.Next()
+		}
+		// 选择q-value最高的压缩方式，相同时按配置的顺序
+		var compressor StreamCompressor
+		encoding := ""
+		quality := 0.0
+		for _, item := range compressorList {
+			acceptable, itemEncoding := item.Accept(c, -1)
+			if !acceptable {
+				continue
+			}
+			q := AcceptEncodingQuality(c, itemEncoding)
+			if compressor == nil || q > quality {
+				compressor = item
+				encoding = itemEncoding
+				quality = q
+			}
+		}
+		if compressor == nil {
+			return c.Next()
+		}
+		resp := c.Response
+		w := newCompressResponseWriter(c, checker, compressor, encoding)
+		c.Response = w
+		defer func() {
+			c.Response = resp
+		}()
+		err = c.Next()
+		if err != nil {
+			// 如果已写入数据，则需要完成压缩，否则由出错处理生成响应
+			if w.decided {
+				_ = w.Close()
+			}
+			return
+		}
+		if !c.Committed {
+			// 由当前中间件写入响应数据
+			c.Committed = true
+			if c.StatusCode != 0 {
+				w.WriteHeader(c.StatusCode)
+			}
+			var e error
+			if c.BodyBuffer != nil {
+				c.SetHeader(elton.HeaderContentLength, strconv.Itoa(c.BodyBuffer.Len()))
+				_, e = w.Write(c.BodyBuffer.Bytes())
+			} else if c.IsReaderBody() {
+				r, _ := c.Body.(io.Reader)
+				if closer, ok := r.(io.Closer); ok {
+					defer closer.Close()
+				}
+				_, e = io.Copy(w, r)
+			}
+			if e != nil && c.Elton() != nil {
+				c.Elton().EmitError(c, e)
+			}
+		}
+		e := w.Close()
+		if e != nil && c.Elton() != nil {
+			c.Elton().EmitError(c, e)
+		}
+		return
+	}
+}
@@ -0,0 +1,197 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+	"github.com/vicanso/elton"
+)
+
+func TestCompressorWriterReuse(t *testing.T) {
+	assert := assert.New(t)
+	g := new(GzipCompressor)
+	for i := 0; i < 3; i++ {
+		data := randomString(2048)
+		buf, err := g.Compress([]byte(data))
+		assert.Nil(err)
+		r, err := gzip.NewReader(buf)
+		assert.Nil(err)
+		result, _ := ioutil.ReadAll(r)
+		assert.Equal(data, string(result))
+	}
+}
+
+func TestStreamCompress(t *testing.T) {
+	assert := assert.New(t)
+	htmlData := "<html><body>" + randomString(8192) + "</body></html>"
+
+	newElton := func(handler elton.Handler) *elton.Elton {
+		e := elton.New()
+		e.Use(NewStreamCompress(NewCompressConfig(
+			new(GzipCompressor),
+			new(BrCompressor),
+		)))
+		e.GET("/", handler)
+		return e
+	}
+	newRequest := func(acceptEncoding string) *http.Request {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(elton.HeaderAcceptEncoding, acceptEncoding)
+		return req
+	}
+
+	t.Run("body buffer", func(t *testing.T) {
+		e := newElton(func(c *elton.Context) error {
+			c.SetHeader(elton.HeaderContentType, "text/html")
+			c.SetHeader(elton.HeaderETag, `"123"`)
+			c.Created(nil)
+			c.BodyBuffer = bytes.NewBufferString(htmlData)
+			return nil
+		})
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, newRequest("gzip;q=0.8, br"))
+		assert.Equal(http.StatusCreated, resp.Code)
+		assert.Equal(BrEncoding, resp.Header().Get(elton.HeaderContentEncoding))
+		assert.Equal(elton.HeaderAcceptEncoding, resp.Header().Get(HeaderVary))
+		assert.Equal(`W/"123"`, resp.Header().Get(elton.HeaderETag))
+		assert.Empty(resp.Header().Get(elton.HeaderContentLength))
+		buf, _ := ioutil.ReadAll(brotli.NewReader(resp.Body))
+		assert.Equal(htmlData, string(buf))
+	})
+
+	t.Run("small body", func(t *testing.T) {
+		e := newElton(func(c *elton.Context) error {
+			c.SetHeader(elton.HeaderContentType, "text/html")
+			c.BodyBuffer = bytes.NewBufferString("abcd")
+			return nil
+		})
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, newRequest("gzip"))
+		assert.Empty(resp.Header().Get(elton.HeaderContentEncoding))
+		assert.Equal("4", resp.Header().Get(elton.HeaderContentLength))
+		assert.Equal("abcd", resp.Body.String())
+	})
+
+	t.Run("reader body", func(t *testing.T) {
+		e := newElton(func(c *elton.Context) error {
+			c.Body = strings.NewReader(htmlData)
+			return nil
+		})
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, newRequest("gzip"))
+		// 根据数据检测类型
+		assert.Equal("text/html; charset=utf-8", resp.Header().Get(elton.HeaderContentType))
+		assert.Equal(GzipEncoding, resp.Header().Get(elton.HeaderContentEncoding))
+		r, err := gzip.NewReader(resp.Body)
+		assert.Nil(err)
+		buf, _ := ioutil.ReadAll(r)
+		assert.Equal(htmlData, string(buf))
+	})
+
+	t.Run("not acceptable", func(t *testing.T) {
+		e := newElton(func(c *elton.Context) error {
+			c.SetHeader(elton.HeaderContentType, "text/html")
+			c.BodyBuffer = bytes.NewBufferString(htmlData)
+			return nil
+		})
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, newRequest("identity"))
+		assert.Empty(resp.Header().Get(elton.HeaderContentEncoding))
+		assert.Equal(htmlData, resp.Body.String())
+	})
+
+	t.Run("stream json", func(t *testing.T) {
+		var info *StatsInfo
+		e := elton.New()
+		e.Use(NewStats(StatsConfig{
+			OnStats: func(si *StatsInfo, _ *elton.Context) {
+				info = si
+			},
+		}))
+		e.Use(NewStreamCompress(NewCompressConfig(
+			new(GzipCompressor),
+		)))
+		e.GET("/", func(c *elton.Context) error {
+			count := 0
+			return c.StreamNDJSON(func() (interface{}, error) {
+				if count >= 1000 {
+					return nil, io.EOF
+				}
+				count++
+				return map[string]int{
+					"id": count,
+				}, nil
+			})
+		})
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, newRequest("gzip"))
+		assert.Equal(elton.MIMEApplicationNDJSON, resp.Header().Get(elton.HeaderContentType))
+		assert.Equal(GzipEncoding, resp.Header().Get(elton.HeaderContentEncoding))
+		r, err := gzip.NewReader(resp.Body)
+		assert.Nil(err)
+		buf, _ := ioutil.ReadAll(r)
+		lines := strings.Split(strings.TrimSpace(string(buf)), "\n")
+		assert.Equal(1000, len(lines))
+		assert.Equal(`{"id":1000}`, lines[999])
+		// 统计的是未压缩的数据长度
+		assert.Equal(len(buf), info.Size)
+	})
+
+	t.Run("sse", func(t *testing.T) {
+		event := "data: hello\n\n"
+		flushed := make(chan []byte)
+		var resp *httptest.ResponseRecorder
+		e := newElton(func(c *elton.Context) error {
+			c.Committed = true
+			c.SetHeader(elton.HeaderContentType, "text/event-stream")
+			_, err := c.Response.Write([]byte(event))
+			if err != nil {
+				return err
+			}
+			// 数据少于最小压缩长度，flush后也需要输出
+			c.Response.(http.Flusher).Flush()
+			flushed <- append([]byte(nil), resp.Body.Bytes()...)
+			return nil
+		})
+		resp = httptest.NewRecorder()
+		go e.ServeHTTP(resp, newRequest("gzip"))
+		data := <-flushed
+		assert.Equal(GzipEncoding, resp.Header().Get(elton.HeaderContentEncoding))
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		assert.Nil(err)
+		buf := make([]byte, len(event))
+		_, err = io.ReadFull(r, buf)
+		assert.Nil(err)
+		assert.Equal(event, string(buf))
+	})
+}
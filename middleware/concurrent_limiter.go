@@ -90,19 +90,18 @@ type (
 	GlobalConcurrentLimiterConfig struct {
 		Skipper elton.Skipper
 		Max     uint32
+		// Queue wait queue, the request will wait in queue instead of failing when the max is hit
+		Queue *ConcurrentLimiterQueue
 	}
 )
 
-// NewConcurrentLimiter returns a new concurrent limiter middleware.
-// It will throw a panic if Lock function is nil.
-func NewConcurrentLimiter(config ConcurrentLimiterConfig) elton.Handler {
-
-	if config.Lock == nil {
-		panic(ErrRequireLockFunction)
-	}
+// parseLimiterKeys parses the keys for limiter, the key can be
+// ":ip"(real ip), "h:name"(request header), "q:name"(query),
+// "p:name"(route param) or field of request body(json)
+func parseLimiterKeys(configKeys []string) []*concurrentLimiterKeyInfo {
 	keys := make([]*concurrentLimiterKeyInfo, 0)
 	// 根据配置生成key的处理
-	for _, key := range config.Keys {
+	for _, key := range configKeys {
 		if key == ipKey {
 			keys = append(keys, &concurrentLimiterKeyInfo{
 				IP: true,
@@ -135,43 +134,61 @@ func NewConcurrentLimiter(config ConcurrentLimiterConfig) elton.Handler {
 			Body: true,
 		})
 	}
+	return keys
+}
+
+// getLimiterKey gets the value of keys and joins them by ',',
+// it will return an error if the value is empty and notAllowEmpty is true.
+func getLimiterKey(c *elton.Context, keys []*concurrentLimiterKeyInfo, notAllowEmpty bool) (string, error) {
+	keyLength := len(keys)
+	sb := new(strings.Builder)
+	// 先申请假定每个value的长度
+	sb.Grow(8 * keyLength)
+	for i, key := range keys {
+		v := ""
+		name := key.Name
+		if key.IP {
+			v = c.RealIP()
+		} else if key.Header {
+			v = c.GetRequestHeader(name)
+		} else if key.Query {
+			v = c.QueryParam(name)
+		} else if key.Params {
+			v = c.Param(name)
+		} else {
+			v = gjson.GetBytes(c.RequestBody, name).String()
+		}
+		if notAllowEmpty && len(v) == 0 {
+			return "", ErrNotAllowEmpty
+		}
+		sb.WriteString(v)
+		if i < keyLength-1 {
+			sb.WriteRune(',')
+		}
+	}
+	return sb.String(), nil
+}
+
+// NewConcurrentLimiter returns a new concurrent limiter middleware.
+// It will throw a panic if Lock function is nil.
+func NewConcurrentLimiter(config ConcurrentLimiterConfig) elton.Handler {
+
+	if config.Lock == nil {
+		panic(ErrRequireLockFunction)
+	}
+	keys := parseLimiterKeys(config.Keys)
 	skipper := config.Skipper
 	if skipper == nil {
 		skipper = elton.DefaultSkipper
 	}
-	keyLength := len(keys)
 	return func(c *elton.Context) (err error) {
 		if skipper(c) {
 			return c.Next()
 		}
-		sb := new(strings.Builder)
-		// 先申请假定每个value的长度
-		sb.Grow(8 * keyLength)
-		// 获取 lock 的key
-		for i, key := range keys {
-			v := ""
-			name := key.Name
-			if key.IP {
-				v = c.RealIP()
-			} else if key.Header {
-				v = c.GetRequestHeader(name)
-			} else if key.Query {
-				v = c.QueryParam(name)
-			} else if key.Params {
-				v = c.Param(name)
-			} else {
-				v = gjson.GetBytes(c.RequestBody, name).String()
-			}
-			if config.NotAllowEmpty && len(v) == 0 {
-				err = ErrNotAllowEmpty
-				return
-			}
-			sb.WriteString(v)
-			if i < keyLength-1 {
-				sb.WriteRune(',')
-			}
+		lockKey, err := getLimiterKey(c, keys, config.NotAllowEmpty)
+		if err != nil {
+			return
 		}
-		lockKey := sb.String()
 
 		success, unlock, err := config.Lock(lockKey, c)
 		if err != nil {
@@ -193,7 +210,18 @@ func NewConcurrentLimiter(config ConcurrentLimiterConfig) elton.Handler {
 
 // NewGlobalConcurrentLimiter returns a new global concurrent limiter,
 // it use for global processing request limit.
+// If the queue is set, the request will wait in queue for a short time when the max is hit.
 func NewGlobalConcurrentLimiter(config GlobalConcurrentLimiterConfig) elton.Handler {
+	if queue := config.Queue; queue != nil {
+		return func(c *elton.Context) (err error) {
+			err = queue.acquire(c, config.Max)
+			if err != nil {
+				return
+			}
+			defer queue.release()
+			return c.Next()
+		}
+	}
 	var count uint32
 	return func(c *elton.Context) (err error) {
 		value := atomic.AddUint32(&count, 1)
@@ -0,0 +1,209 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vicanso/elton"
+)
+
+const (
+	// DefaultLockTTL default ttl of lock
+	DefaultLockTTL    = time.Minute
+	defaultLockShards = 32
+)
+
+type (
+	// ConcurrentLimiterLocker locker interface for concurrent limiter.
+	// It can be implemented by redis(SET key token NX PX ttl) for distributed lock,
+	// the unlock should only delete the key when the value of key is equal to token,
+	// because the lock may be expired and locked by another request.
+	ConcurrentLimiterLocker interface {
+		// Lock locks the key with token, returns false if the key is locked
+		Lock(key, token string, ttl time.Duration) (bool, error)
+		// Unlock unlocks the key if it's locked by the token
+		Unlock(key, token string) error
+	}
+	localLockItem struct {
+		token     string
+		expiredAt time.Time
+	}
+	localLockShard struct {
+		mutex sync.Mutex
+		m     map[string]*localLockItem
+	}
+	// LocalLockStats stats of local lock
+	LocalLockStats struct {
+		// Held the count of held keys
+		Held int `json:"held"`
+		// Acquired the count of acquired lock
+		Acquired uint64 `json:"acquired"`
+		// Rejected the count of rejected lock
+		Rejected uint64 `json:"rejected"`
+		// Expired the count of expired lock which is reclaimed
+		Expired uint64 `json:"expired"`
+	}
+	// LocalLock local lock with sharded maps, the lock will be expired after ttl,
+	// so the crashed request can't hold the key forever.
+	LocalLock struct {
+		shards   []*localLockShard
+		acquired uint64
+		rejected uint64
+		expired  uint64
+		now      func() time.Time
+	}
+)
+
+var _ ConcurrentLimiterLocker = (*LocalLock)(nil)
+
+// NewLocalLock returns a new local lock
+func NewLocalLock() *LocalLock {
+	shards := make([]*localLockShard, defaultLockShards)
+	for i := range shards {
+		shards[i] = &localLockShard{
+			m: make(map[string]*localLockItem),
+		}
+	}
+	return &LocalLock{
+		shards: shards,
+		now:    time.Now,
+	}
+}
+
+func (l *LocalLock) getShard(key string) *localLockShard {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return l.shards[h.Sum32()%uint32(len(l.shards))]
+}
+
+// Lock locks the key with token, if the key is locked and not expired, it will return false
+func (l *LocalLock) Lock(key, token string, ttl time.Duration) (bool, error) {
+	shard := l.getShard(key)
+	now := l.now()
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	item, ok := shard.m[key]
+	if ok {
+		if now.Before(item.expiredAt) {
+			atomic.AddUint64(&l.rejected, 1)
+			return false, nil
+		}
+		atomic.AddUint64(&l.expired, 1)
+	}
+	shard.m[key] = &localLockItem{
+		token:     token,
+		expiredAt: now.Add(ttl),
+	}
+	atomic.AddUint64(&l.acquired, 1)
+	return true, nil
+}
+
+// Unlock unlocks the key if it's locked by the token
+func (l *LocalLock) Unlock(key, token string) error {
+	shard := l.getShard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	item, ok := shard.m[key]
+	if ok && item.token == token {
+		delete(shard.m, key)
+	}
+	return nil
+}
+
+// HeldKeys returns the sorted keys which are held and not expired,
+// the expired keys will be removed.
+func (l *LocalLock) HeldKeys() []string {
+	now := l.now()
+	keys := make([]string, 0)
+	for _, shard := range l.shards {
+		shard.mutex.Lock()
+		for key, item := range shard.m {
+			if now.Before(item.expiredAt) {
+				keys = append(keys, key)
+				continue
+			}
+			delete(shard.m, key)
+			atomic.AddUint64(&l.expired, 1)
+		}
+		shard.mutex.Unlock()
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Stats returns the stats of local lock
+func (l *LocalLock) Stats() LocalLockStats {
+	held := len(l.HeldKeys())
+	return LocalLockStats{
+		Held:     held,
+		Acquired: atomic.LoadUint64(&l.acquired),
+		Rejected: atomic.LoadUint64(&l.rejected),
+		Expired:  atomic.LoadUint64(&l.expired),
+	}
+}
+
+func generateLockToken() (string, error) {
+	buf := make([]byte, 16)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// NewConcurrentLimiterLock returns a lock function of concurrent limiter using the locker,
+// the lock will be expired after ttl(default is one minute).
+func NewConcurrentLimiterLock(locker ConcurrentLimiterLocker, ttl time.Duration) ConcurrentLimiterLock {
+	if ttl <= 0 {
+		ttl = DefaultLockTTL
+	}
+	return func(key string, c *elton.Context) (bool, func(), error) {
+		token, err := generateLockToken()
+		if err != nil {
+			return false, nil, err
+		}
+		success, err := locker.Lock(key, token, ttl)
+		if err != nil || !success {
+			return false, nil, err
+		}
+		return true, func() {
+			err := locker.Unlock(key, token)
+			// 解锁失败时触发出错事件，锁会在ttl后过期
+			if err != nil && c.Elton() != nil {
+				c.Elton().EmitError(c, err)
+			}
+		}, nil
+	}
+}
+
+// NewLocalConcurrentLimiterLock returns a lock function of concurrent limiter using local lock
+func NewLocalConcurrentLimiterLock(ttl time.Duration) ConcurrentLimiterLock {
+	return NewConcurrentLimiterLock(NewLocalLock(), ttl)
+}
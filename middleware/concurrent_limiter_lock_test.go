@@ -0,0 +1,153 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"errors"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vicanso/elton"
+)
+
+// fakeDistributedLock simulates the distributed lock(redis SET NX PX)
+type fakeDistributedLock struct {
+	mutex sync.Mutex
+	m     map[string]string
+	err   error
+}
+
+func (l *fakeDistributedLock) Lock(key, token string, ttl time.Duration) (bool, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.err != nil {
+		return false, l.err
+	}
+	if _, ok := l.m[key]; ok {
+		return false, nil
+	}
+	l.m[key] = token
+	return true, nil
+}
+
+func (l *fakeDistributedLock) Unlock(key, token string) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.m[key] == token {
+		delete(l.m, key)
+	}
+	return nil
+}
+
+func TestLocalLock(t *testing.T) {
+	assert := assert.New(t)
+	clock := newFakeClock()
+	l := NewLocalLock()
+	l.now = clock.Now
+
+	success, err := l.Lock("a", "1", time.Second)
+	assert.Nil(err)
+	assert.True(success)
+
+	success, _ = l.Lock("a", "2", time.Second)
+	assert.False(success)
+
+	success, _ = l.Lock("b", "1", time.Second)
+	assert.True(success)
+	assert.Equal([]string{"a", "b"}, l.HeldKeys())
+
+	// 过期后可重新获取锁
+	clock.Add(2 * time.Second)
+	success, _ = l.Lock("a", "3", time.Second)
+	assert.True(success)
+
+	// 过期的锁不可解除其它请求的锁
+	assert.Nil(l.Unlock("a", "1"))
+	assert.Equal([]string{"a"}, l.HeldKeys())
+	assert.Equal(LocalLockStats{
+		Held:     1,
+		Acquired: 3,
+		Rejected: 1,
+		Expired:  2,
+	}, l.Stats())
+
+	assert.Nil(l.Unlock("a", "3"))
+	assert.Empty(l.HeldKeys())
+}
+
+func TestConcurrentLimiterLock(t *testing.T) {
+	assert := assert.New(t)
+	fake := &fakeDistributedLock{
+		m: make(map[string]string),
+	}
+	lock := NewConcurrentLimiterLock(fake, 0)
+	c := elton.NewContext(nil, httptest.NewRequest("POST", "/", nil))
+
+	success, unlock, err := lock("a", c)
+	assert.Nil(err)
+	assert.True(success)
+	assert.NotNil(unlock)
+
+	success, _, err = lock("a", c)
+	assert.Nil(err)
+	assert.False(success)
+
+	unlock()
+	success, _, _ = lock("a", c)
+	assert.True(success)
+
+	fake.err = errors.New("lock error")
+	_, _, err = lock("b", c)
+	assert.Equal(fake.err, err)
+}
+
+func TestLocalConcurrentLimiterLock(t *testing.T) {
+	assert := assert.New(t)
+	skipErr := errors.New("skip error")
+	fn := NewConcurrentLimiter(ConcurrentLimiterConfig{
+		Keys: []string{
+			"p:id",
+		},
+		Lock: NewLocalConcurrentLimiterLock(time.Second),
+	})
+	newContext := func(next func() error) *elton.Context {
+		c := elton.NewContext(nil, httptest.NewRequest("POST", "/", nil))
+		c.Params.Add("id", "1")
+		c.Next = next
+		return c
+	}
+
+	c := newContext(func() error {
+		// 处理中时相同的请求被拒绝
+		assert.Equal(ErrSubmitTooFrequently, fn(newContext(nil)))
+		return skipErr
+	})
+	assert.Equal(skipErr, fn(c))
+	// 完成后已解锁
+	assert.Equal(skipErr, fn(newContext(func() error {
+		return skipErr
+	})))
+}
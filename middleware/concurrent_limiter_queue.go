@@ -0,0 +1,241 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"container/heap"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/vicanso/elton"
+	"github.com/vicanso/hes"
+)
+
+const (
+	// DefaultQueueMaxWait default max wait time of queue
+	DefaultQueueMaxWait = time.Second
+	// DefaultQueueSize default max size of queue
+	DefaultQueueSize = 100
+)
+
+var (
+	// ErrQueueWaitTimeout wait timeout in queue
+	ErrQueueWaitTimeout = &hes.Error{
+		StatusCode: http.StatusTooManyRequests,
+		Message:    "wait timeout in queue",
+		Category:   ErrConcurrentLimiterCategory,
+	}
+)
+
+type (
+	// QueuePriority returns the priority of request, the request with higher priority will be processed first
+	QueuePriority func(c *elton.Context) int
+	// ConcurrentLimiterQueueConfig queue config
+	ConcurrentLimiterQueueConfig struct {
+		// Size max size of queue, default is 100
+		Size uint32
+		// MaxWait max wait time in queue, default is one second
+		MaxWait time.Duration
+		// Priority priority function, FIFO if it's nil
+		Priority QueuePriority
+	}
+	// ConcurrentLimiterQueueStats stats of queue
+	ConcurrentLimiterQueueStats struct {
+		// Depth current depth of queue
+		Depth int `json:"depth"`
+		// MaxDepth max depth of queue
+		MaxDepth int `json:"maxDepth"`
+		// Running running count of request
+		Running uint32 `json:"running"`
+		// Enqueued count of enqueued request
+		Enqueued uint64 `json:"enqueued"`
+		// Dequeued count of dequeued request which is processed
+		Dequeued uint64 `json:"dequeued"`
+		// Timeout count of wait timeout request
+		Timeout uint64 `json:"timeout"`
+		// Rejected count of rejected request because the queue is full
+		Rejected uint64 `json:"rejected"`
+	}
+	queueWaiter struct {
+		priority int
+		seq      uint64
+		index    int
+		done     chan struct{}
+	}
+	queueWaiters []*queueWaiter
+	// ConcurrentLimiterQueue wait queue of global concurrent limiter,
+	// it should not be shared by multiple limiters.
+	ConcurrentLimiterQueue struct {
+		mutex    sync.Mutex
+		size     int
+		maxWait  time.Duration
+		priority QueuePriority
+		seq      uint64
+		running  uint32
+		waiters  queueWaiters
+		stats    ConcurrentLimiterQueueStats
+	}
+)
+
+func (qw queueWaiters) Len() int {
+	return len(qw)
+}
+
+func (qw queueWaiters) Less(i, j int) bool {
+	if qw[i].priority != qw[j].priority {
+		return qw[i].priority > qw[j].priority
+	}
+	return qw[i].seq < qw[j].seq
+}
+
+func (qw queueWaiters) Swap(i, j int) {
+	qw[i], qw[j] = qw[j], qw[i]
+	qw[i].index = i
+	qw[j].index = j
+}
+
+func (qw *queueWaiters) Push(x interface{}) {
+	w := x.(*queueWaiter)
+	w.index = len(*qw)
+	*qw = append(*qw, w)
+}
+
+func (qw *queueWaiters) Pop() interface{} {
+	old := *qw
+	n := len(old)
+	w := old[n-1]
+	old[n-1] = nil
+	w.index = -1
+	*qw = old[:n-1]
+	return w
+}
+
+// NewConcurrentLimiterQueue returns a new queue for global concurrent limiter
+func NewConcurrentLimiterQueue(config ConcurrentLimiterQueueConfig) *ConcurrentLimiterQueue {
+	maxWait := config.MaxWait
+	if maxWait <= 0 {
+		maxWait = DefaultQueueMaxWait
+	}
+	size := int(config.Size)
+	if size == 0 {
+		size = DefaultQueueSize
+	}
+	return &ConcurrentLimiterQueue{
+		size:     size,
+		maxWait:  maxWait,
+		priority: config.Priority,
+	}
+}
+
+// NewRouteQueuePriority returns a priority function by route, the key is "method route"
+func NewRouteQueuePriority(priorities map[string]int) QueuePriority {
+	return func(c *elton.Context) int {
+		return priorities[c.Request.Method+" "+c.Route]
+	}
+}
+
+// NewHeaderQueuePriority returns a priority function by the value of request header
+func NewHeaderQueuePriority(header string, priorities map[string]int) QueuePriority {
+	return func(c *elton.Context) int {
+		return priorities[c.GetRequestHeader(header)]
+	}
+}
+
+// Stats returns the stats of queue
+func (q *ConcurrentLimiterQueue) Stats() ConcurrentLimiterQueueStats {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	stats := q.stats
+	stats.Depth = len(q.waiters)
+	stats.Running = q.running
+	return stats
+}
+
+// acquire acquires a slot, it will wait in queue if the running count is hit the max
+func (q *ConcurrentLimiterQueue) acquire(c *elton.Context, max uint32) error {
+	q.mutex.Lock()
+	// 与无队列时的判断保持一致
+	if q.running+1 < max && len(q.waiters) == 0 {
+		q.running++
+		q.mutex.Unlock()
+		return nil
+	}
+	if len(q.waiters) >= q.size {
+		q.stats.Rejected++
+		q.mutex.Unlock()
+		return ErrTooManyRequests
+	}
+	priority := 0
+	if q.priority != nil {
+		priority = q.priority(c)
+	}
+	q.seq++
+	w := &queueWaiter{
+		priority: priority,
+		seq:      q.seq,
+		// 有缓冲，避免release时阻塞
+		done: make(chan struct{}, 1),
+	}
+	heap.Push(&q.waiters, w)
+	q.stats.Enqueued++
+	if len(q.waiters) > q.stats.MaxDepth {
+		q.stats.MaxDepth = len(q.waiters)
+	}
+	q.mutex.Unlock()
+
+	timer := time.NewTimer(q.maxWait)
+	defer timer.Stop()
+	var done <-chan struct{}
+	if c.Request != nil {
+		done = c.Context().Done()
+	}
+	select {
+	case <-w.done:
+		return nil
+	case <-timer.C:
+	case <-done:
+	}
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	// 已被分配至执行
+	if w.index < 0 {
+		return nil
+	}
+	heap.Remove(&q.waiters, w.index)
+	q.stats.Timeout++
+	return ErrQueueWaitTimeout
+}
+
+// release releases the slot, the slot will be transferred to the first waiter of queue
+func (q *ConcurrentLimiterQueue) release() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if len(q.waiters) == 0 {
+		q.running--
+		return
+	}
+	w := heap.Pop(&q.waiters).(*queueWaiter)
+	q.stats.Dequeued++
+	w.done <- struct{}{}
+}
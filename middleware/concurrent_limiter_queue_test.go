@@ -0,0 +1,147 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vicanso/elton"
+)
+
+func TestConcurrentLimiterQueue(t *testing.T) {
+	assert := assert.New(t)
+	queue := NewConcurrentLimiterQueue(ConcurrentLimiterQueueConfig{
+		Size:     2,
+		MaxWait:  time.Second,
+		Priority: NewHeaderQueuePriority("X-Priority", map[string]int{"high": 10}),
+	})
+	fn := NewGlobalConcurrentLimiter(GlobalConcurrentLimiterConfig{
+		Max:   2,
+		Queue: queue,
+	})
+	release := make(chan struct{})
+	mutex := sync.Mutex{}
+	result := make([]string, 0)
+	newContext := func(name, priority string) *elton.Context {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Priority", priority)
+		c := elton.NewContext(nil, req)
+		c.Next = func() error {
+			mutex.Lock()
+			result = append(result, name)
+			mutex.Unlock()
+			if name == "first" {
+				<-release
+			}
+			return nil
+		}
+		return c
+	}
+
+	wg := sync.WaitGroup{}
+	// 等待请求开始处理或进入队列后再执行下一请求
+	run := func(name, priority string, ready func(stats ConcurrentLimiterQueueStats) bool) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Nil(fn(newContext(name, priority)))
+		}()
+		for i := 0; i < 1000 && !ready(queue.Stats()); i++ {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	run("first", "", func(stats ConcurrentLimiterQueueStats) bool {
+		return stats.Running == 1
+	})
+	run("low", "", func(stats ConcurrentLimiterQueueStats) bool {
+		return stats.Depth == 1
+	})
+	run("high", "high", func(stats ConcurrentLimiterQueueStats) bool {
+		return stats.Depth == 2
+	})
+
+	// 队列已满
+	assert.Equal(ErrTooManyRequests, fn(newContext("full", "")))
+	stats := queue.Stats()
+	assert.Equal(2, stats.Depth)
+	assert.Equal(uint32(1), stats.Running)
+	assert.Equal(uint64(1), stats.Rejected)
+
+	close(release)
+	wg.Wait()
+	assert.Equal([]string{"first", "high", "low"}, result)
+	assert.Equal(ConcurrentLimiterQueueStats{
+		MaxDepth: 2,
+		Enqueued: 2,
+		Dequeued: 2,
+		Rejected: 1,
+	}, queue.Stats())
+}
+
+func TestConcurrentLimiterQueueTimeout(t *testing.T) {
+	assert := assert.New(t)
+	queue := NewConcurrentLimiterQueue(ConcurrentLimiterQueueConfig{
+		Size:    1,
+		MaxWait: 10 * time.Millisecond,
+	})
+	fn := NewGlobalConcurrentLimiter(GlobalConcurrentLimiterConfig{
+		Max:   2,
+		Queue: queue,
+	})
+	req := httptest.NewRequest("GET", "/", nil)
+	c := elton.NewContext(nil, req)
+	c.Next = func() error {
+		// 处理中时，其它请求等待超时
+		other := elton.NewContext(nil, httptest.NewRequest("GET", "/", nil))
+		assert.Equal(ErrQueueWaitTimeout, fn(other))
+		return nil
+	}
+	assert.Nil(fn(c))
+	stats := queue.Stats()
+	assert.Equal(uint64(1), stats.Timeout)
+	assert.Equal(0, stats.Depth)
+	assert.Equal(uint32(0), stats.Running)
+}
+
+func TestRouteQueuePriority(t *testing.T) {
+	assert := assert.New(t)
+	fn := NewRouteQueuePriority(map[string]int{
+		"GET /users/me": 1,
+	})
+	c := elton.NewContext(nil, httptest.NewRequest("GET", "/users/me", nil))
+	c.Route = "/users/me"
+	assert.Equal(1, fn(c))
+	c.Route = "/"
+	assert.Equal(0, fn(c))
+}
+
+func TestNewConcurrentLimiterQueue(t *testing.T) {
+	assert := assert.New(t)
+	queue := NewConcurrentLimiterQueue(ConcurrentLimiterQueueConfig{})
+	assert.Equal(DefaultQueueSize, queue.size)
+	assert.Equal(DefaultQueueMaxWait, queue.maxWait)
+}
@@ -0,0 +1,124 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/vicanso/elton"
+)
+
+const (
+	// DeflateEncoding deflate encoding
+	DeflateEncoding = "deflate"
+)
+
+type (
+	// DeflateCompressor deflate compress
+	DeflateCompressor struct {
+		Level     int
+		MinLength int
+		pool      sync.Pool
+	}
+)
+
+// Accept accept deflate encoding
+func (d *DeflateCompressor) Accept(c *elton.Context, bodySize int) (acceptable bool, encoding string) {
+	// 如果数据少于最低压缩长度，则不压缩（reader的bodySize为-1）
+	if bodySize >= 0 && bodySize < d.getMinLength() {
+		return
+	}
+	return AcceptEncoding(c, DeflateEncoding)
+}
+
+// Compress compress data by deflate
+func (d *DeflateCompressor) Compress(buf []byte) (*bytes.Buffer, error) {
+	buffer := new(bytes.Buffer)
+	w := d.Writer(buffer)
+	defer d.Release(w)
+	_, err := w.Write(buf)
+	if err != nil {
+		return nil, err
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buffer, nil
+}
+
+// Writer returns a deflate writer from pool
+func (d *DeflateCompressor) Writer(w io.Writer) CompressWriter {
+	if v := d.pool.Get(); v != nil {
+		fw := v.(*flate.Writer)
+		fw.Reset(w)
+		return fw
+	}
+	// level 已限制在有效范围，不会出错
+	fw, _ := flate.NewWriter(w, d.getLevel())
+	return fw
+}
+
+// Release puts the deflate writer back to pool
+func (d *DeflateCompressor) Release(w CompressWriter) {
+	w.Reset(ioutil.Discard)
+	d.pool.Put(w)
+}
+
+func (d *DeflateCompressor) getLevel() int {
+	level := d.Level
+	if level <= 0 {
+		level = flate.DefaultCompression
+	}
+	if level > flate.BestCompression {
+		level = flate.BestCompression
+	}
+	return level
+}
+
+func (d *DeflateCompressor) getMinLength() int {
+	if d.MinLength == 0 {
+		return DefaultCompressMinLength
+	}
+	return d.MinLength
+}
+
+// Pipe compress by pipe
+func (d *DeflateCompressor) Pipe(c *elton.Context) (err error) {
+	r := c.Body.(io.Reader)
+	closer, ok := c.Body.(io.Closer)
+	if ok {
+		defer closer.Close()
+	}
+	w := d.Writer(c.Response)
+	defer d.Release(w)
+	_, err = io.Copy(w, r)
+	if err != nil {
+		return
+	}
+	return w.Close()
+}
@@ -24,6 +24,8 @@ package middleware
 
 import (
 	"bytes"
+	"encoding/json"
+	"html/template"
 	"net/http"
 	"strings"
 
@@ -34,16 +36,50 @@ import (
 type (
 	// ErrorConfig error handler config
 	ErrorConfig struct {
-		Skipper      elton.Skipper
+		Skipper elton.Skipper
+		// ResponseType the response type of error, json, problem or envelope,
+		// it will use the accept header of request if it's empty.
 		ResponseType string
+		// Problems the registry of problem types for problem response
+		Problems *ProblemRegistry
+		// HTMLTemplate the template of html error page, it's used for the request
+		// whose accept header contains text/html. The data of template is *Problem.
+		HTMLTemplate *template.Template
+		// Registry the error registry which converts error to hes.Error,
+		// it will use the error registry of elton if it's nil.
+		// The converted error with full details will be emitted to error listeners.
+		Registry *elton.ErrorRegistry
 	}
 )
 
 const (
 	// ErrErrorCategory error category of error handler
 	ErrErrorCategory = "elton-error"
+
+	// ErrorResponseTypeJSON json response of error
+	ErrorResponseTypeJSON = "json"
+	// ErrorResponseTypeProblem problem details(RFC 7807) response of error
+	ErrorResponseTypeProblem = "problem"
+	// ErrorResponseTypeEnvelope json response of error wrapped as {"error": ...},
+	// it's used with the envelope of responder
+	ErrorResponseTypeEnvelope = "envelope"
 )
 
+// DefaultErrorHTMLTemplate the default template of html error page
+var DefaultErrorHTMLTemplate = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Status}} {{.Title}}</title>
+<style>body{font-family:sans-serif;margin:60px auto;max-width:600px;color:#333}h1{font-weight:normal}p{color:#666}</style>
+</head>
+<body>
+<h1>{{.Status}} {{.Title}}</h1>
+{{if .Detail}}<p>{{.Detail}}</p>{{end}}
+{{if .Instance}}<p><small>{{.Instance}}</small></p>{{end}}
+</body>
+</html>`))
+
 // NewDefaultError return a new error handler, it will convert the error to hes.Error and response.
 // JSON will be used is client's request accept header support application/json, otherwise text will be used.
 func NewDefaultError() elton.Handler {
@@ -65,8 +101,21 @@ func NewError(config ErrorConfig) elton.Handler {
 		if err == nil {
 			return nil
 		}
-		he, ok := err.(*hes.Error)
-		if !ok {
+		registry := config.Registry
+		if registry == nil && c.Elton() != nil {
+			registry = c.Elton().ErrorRegistry
+		}
+		var he *hes.Error
+		if registry != nil {
+			full := registry.Convert(err)
+			// 出错已处理，因此由当前中间件触发出错事件
+			if c.Elton() != nil {
+				c.Elton().EmitError(c, full)
+			}
+			he = registry.Public(full)
+		} else if e, ok := err.(*hes.Error); ok {
+			he = e
+		} else {
 			he = hes.Wrap(err)
 			// 非hes的error，则都认为是500出错异常
 			he.StatusCode = http.StatusInternalServerError
@@ -74,8 +123,50 @@ func NewError(config ErrorConfig) elton.Handler {
 			he.Category = ErrErrorCategory
 		}
 		c.StatusCode = he.StatusCode
-		if config.ResponseType == "json" ||
-			strings.Contains(c.GetRequestHeader("Accept"), "application/json") {
+		accept := c.GetRequestHeader("Accept")
+		isHTML := config.HTMLTemplate != nil && strings.Contains(accept, "text/html")
+		if !isHTML && config.ResponseType == ErrorResponseTypeEnvelope {
+			buf, e := json.Marshal(map[string]interface{}{
+				"error": he,
+			})
+			if e != nil {
+				return e
+			}
+			c.SetHeader(elton.HeaderContentType, elton.MIMEApplicationJSON)
+			c.BodyBuffer = bytes.NewBuffer(buf)
+			return nil
+		}
+		if isHTML ||
+			config.ResponseType == ErrorResponseTypeProblem ||
+			strings.Contains(accept, MIMEApplicationProblemJSON) {
+			var pt *ProblemType
+			if config.Problems != nil {
+				// 使用原始的error匹配
+				pt = config.Problems.Lookup(err)
+			}
+			p := NewProblem(c, he, pt)
+			c.StatusCode = p.Status
+			buf := new(bytes.Buffer)
+			contentType := MIMEApplicationProblemJSON
+			var e error
+			if isHTML {
+				contentType = elton.MIMETextHTML
+				e = config.HTMLTemplate.Execute(buf, p)
+			} else {
+				var data []byte
+				data, e = json.Marshal(p)
+				buf.Write(data)
+			}
+			// 生成失败则返回出错信息
+			if e != nil {
+				return e
+			}
+			c.SetHeader(elton.HeaderContentType, contentType)
+			c.BodyBuffer = buf
+			return nil
+		}
+		if config.ResponseType == ErrorResponseTypeJSON ||
+			strings.Contains(accept, "application/json") {
 			buf := he.ToJSON()
 			c.BodyBuffer = bytes.NewBuffer(buf)
 			c.SetHeader(elton.HeaderContentType, elton.MIMEApplicationJSON)
@@ -31,6 +31,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/vicanso/elton"
+	"github.com/vicanso/hes"
 )
 
 func TestErrorHandler(t *testing.T) {
@@ -122,3 +123,149 @@ func TestErrorHandler(t *testing.T) {
 		assert.Equal(tt.contentType, c.GetHeader(elton.HeaderContentType))
 	}
 }
+
+func TestErrorHandlerProblem(t *testing.T) {
+	assert := assert.New(t)
+
+	fn := NewError(ErrorConfig{
+		ResponseType: ErrorResponseTypeProblem,
+		Problems: NewProblemRegistry().RegisterCategory("validate", ProblemType{
+			Type:       "https://example.com/problems/validate",
+			StatusCode: 422,
+		}),
+		HTMLTemplate: DefaultErrorHTMLTemplate,
+	})
+
+	req := httptest.NewRequest("GET", "/users/me", nil)
+	resp := httptest.NewRecorder()
+	c := elton.NewContext(resp, req)
+	c.ID = "abcd"
+	c.Next = func() error {
+		he := hes.New("name is invalid", "validate")
+		he.Extra = map[string]interface{}{
+			"field": "name",
+		}
+		return he
+	}
+	err := fn(c)
+	assert.Nil(err)
+	assert.Equal(422, c.StatusCode)
+	assert.Equal(MIMEApplicationProblemJSON, c.GetHeader(elton.HeaderContentType))
+	assert.Equal(`{"detail":"name is invalid","field":"name","instance":"abcd","status":422,"title":"Unprocessable Entity","type":"https://example.com/problems/validate"}`, c.BodyBuffer.String())
+
+	// 浏览器返回html
+	req = httptest.NewRequest("GET", "/users/me", nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,*/*;q=0.8")
+	c = elton.NewContext(httptest.NewRecorder(), req)
+	c.Next = func() error {
+		return errors.New("<abc>")
+	}
+	err = fn(c)
+	assert.Nil(err)
+	assert.Equal(500, c.StatusCode)
+	assert.Equal(elton.MIMETextHTML, c.GetHeader(elton.HeaderContentType))
+	assert.Contains(c.BodyBuffer.String(), "<title>500 Internal Server Error</title>")
+	assert.Contains(c.BodyBuffer.String(), "<p>&lt;abc&gt;</p>")
+
+	// 根据accept返回problem
+	fn = NewDefaultError()
+	req = httptest.NewRequest("GET", "/users/me", nil)
+	req.Header.Set("Accept", MIMEApplicationProblemJSON)
+	c = elton.NewContext(httptest.NewRecorder(), req)
+	c.Next = func() error {
+		return hes.New("abc")
+	}
+	err = fn(c)
+	assert.Nil(err)
+	assert.Equal(400, c.StatusCode)
+	assert.Equal(`{"detail":"abc","status":400,"title":"Bad Request","type":"about:blank"}`, c.BodyBuffer.String())
+}
+
+func TestErrorHandlerRegistry(t *testing.T) {
+	assert := assert.New(t)
+
+	notFound := errors.New("not found")
+	e := elton.New()
+	e.ErrorRegistry = elton.NewErrorRegistry(elton.ErrorRegistryConfig{
+		CaptureStack:  true,
+		HideException: true,
+	}).Register(notFound, elton.ErrorMapping{
+		StatusCode: 404,
+		Category:   "user",
+	})
+	var emitErr error
+	e.OnError(func(_ *elton.Context, err error) {
+		emitErr = err
+	})
+	e.Use(NewError(ErrorConfig{
+		ResponseType: ErrorResponseTypeJSON,
+	}))
+	e.GET("/users/{id}", func(c *elton.Context) error {
+		if c.Param("id") == "1" {
+			return notFound
+		}
+		return errors.New("connect to db fail")
+	})
+
+	resp := httptest.NewRecorder()
+	e.ServeHTTP(resp, httptest.NewRequest("GET", "/users/1", nil))
+	assert.Equal(404, resp.Code)
+	assert.Equal(`{"statusCode":404,"category":"user","message":"not found"}`, resp.Body.String())
+
+	resp = httptest.NewRecorder()
+	e.ServeHTTP(resp, httptest.NewRequest("GET", "/users/2", nil))
+	assert.Equal(500, resp.Code)
+	assert.Equal(`{"statusCode":500,"category":"elton","message":"Internal Server Error","exception":true}`, resp.Body.String())
+	he, ok := emitErr.(*hes.Error)
+	assert.True(ok)
+	assert.Equal("connect to db fail", he.Message)
+	assert.NotEmpty(elton.ErrorStack(he))
+}
+
+func TestErrorHandlerEnvelope(t *testing.T) {
+	assert := assert.New(t)
+
+	var emitErr error
+	e := elton.New()
+	e.OnError(func(_ *elton.Context, err error) {
+		emitErr = err
+	})
+	e.Use(NewError(ErrorConfig{
+		ResponseType: ErrorResponseTypeEnvelope,
+	}))
+	e.Use(NewResponder(ResponderConfig{
+		Envelope: true,
+	}))
+	e.GET("/users", func(c *elton.Context) error {
+		_, err := c.ParsePagination(10, 100)
+		if err != nil {
+			return err
+		}
+		c.Body = []string{}
+		return nil
+	})
+	e.GET("/error", func(c *elton.Context) error {
+		return errors.New("abc")
+	})
+
+	resp := httptest.NewRecorder()
+	e.ServeHTTP(resp, httptest.NewRequest("GET", "/users?page=a", nil))
+	assert.Equal(400, resp.Code)
+	assert.Equal(elton.MIMEApplicationJSON, resp.Header().Get(elton.HeaderContentType))
+	assert.Equal(`{"error":{"statusCode":400,"category":"elton","message":"invalid pagination"}}`, resp.Body.String())
+
+	resp = httptest.NewRecorder()
+	e.ServeHTTP(resp, httptest.NewRequest("GET", "/error", nil))
+	assert.Equal(500, resp.Code)
+	assert.Equal(`{"error":{"statusCode":500,"category":"elton-error","message":"abc","exception":true}}`, resp.Body.String())
+
+	// 使用registry时隐藏异常出错信息，并触发出错事件
+	e.ErrorRegistry = elton.NewErrorRegistry(elton.ErrorRegistryConfig{
+		HideException: true,
+	})
+	resp = httptest.NewRecorder()
+	e.ServeHTTP(resp, httptest.NewRequest("GET", "/error", nil))
+	assert.Equal(500, resp.Code)
+	assert.Equal(`{"error":{"statusCode":500,"category":"elton","message":"Internal Server Error","exception":true}}`, resp.Body.String())
+	assert.Equal("abc", emitErr.(*hes.Error).Message)
+}
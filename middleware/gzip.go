@@ -26,6 +26,8 @@ import (
 	"bytes"
 	"compress/gzip"
 	"io"
+	"io/ioutil"
+	"sync"
 
 	"github.com/vicanso/elton"
 )
@@ -40,6 +42,7 @@ type (
 	GzipCompressor struct {
 		Level     int
 		MinLength int
+		pool      sync.Pool
 	}
 )
 
@@ -54,21 +57,38 @@ func (g *GzipCompressor) Accept(c *elton.Context, bodySize int) (acceptable bool
 
 // Compress compress data by gzip
 func (g *GzipCompressor) Compress(buf []byte) (*bytes.Buffer, error) {
-	level := g.getLevel()
 	buffer := new(bytes.Buffer)
-
-	w, err := gzip.NewWriterLevel(buffer, level)
+	w := g.Writer(buffer)
+	defer g.Release(w)
+	_, err := w.Write(buf)
 	if err != nil {
 		return nil, err
 	}
-	defer w.Close()
-	_, err = w.Write(buf)
+	err = w.Close()
 	if err != nil {
 		return nil, err
 	}
 	return buffer, nil
 }
 
+// Writer returns a gzip writer from pool
+func (g *GzipCompressor) Writer(w io.Writer) CompressWriter {
+	if v := g.pool.Get(); v != nil {
+		zw := v.(*gzip.Writer)
+		zw.Reset(w)
+		return zw
+	}
+	// level 已限制在有效范围，不会出错
+	zw, _ := gzip.NewWriterLevel(w, g.getLevel())
+	return zw
+}
+
+// Release puts the gzip writer back to pool
+func (g *GzipCompressor) Release(w CompressWriter) {
+	w.Reset(ioutil.Discard)
+	g.pool.Put(w)
+}
+
 func (g *GzipCompressor) getLevel() int {
 	level := g.Level
 	if level <= 0 {
@@ -94,8 +114,11 @@ func (g *GzipCompressor) Pipe(c *elton.Context) (err error) {
 	if ok {
 		defer closer.Close()
 	}
-	w, _ := gzip.NewWriterLevel(c.Response, g.getLevel())
-	defer w.Close()
+	w := g.Writer(c.Response)
+	defer g.Release(w)
 	_, err = io.Copy(w, r)
-	return
+	if err != nil {
+		return
+	}
+	return w.Close()
 }
@@ -0,0 +1,311 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/vicanso/elton"
+	"github.com/vicanso/hes"
+)
+
+const (
+	// ErrIdempotencyCategory idempotency error category
+	ErrIdempotencyCategory = "elton-idempotency"
+	// DefaultIdempotencyHeader default header of idempotency key
+	DefaultIdempotencyHeader = "Idempotency-Key"
+	// HeaderIdempotentReplayed the header is set to true if the response is replayed
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+	// DefaultIdempotencyTTL default ttl of idempotency record
+	DefaultIdempotencyTTL = 24 * time.Hour
+)
+
+type (
+	// IdempotencyRecord idempotency record
+	IdempotencyRecord struct {
+		// BodyHash the sha256 of request body
+		BodyHash string `json:"bodyHash,omitempty"`
+		// Completed the request is completed, the response is not nil if completed
+		Completed bool            `json:"completed,omitempty"`
+		Response  *CachedResponse `json:"response,omitempty"`
+	}
+	// IdempotencyStore idempotency store
+	IdempotencyStore interface {
+		// SetNX sets the record if the key is not exists, returns false if the key exists
+		SetNX(key string, record *IdempotencyRecord, ttl time.Duration) (bool, error)
+		// Get returns the record of key, returns nil if not exists
+		Get(key string) (*IdempotencyRecord, error)
+		// Set sets the record of key
+		Set(key string, record *IdempotencyRecord, ttl time.Duration) error
+		// Del deletes the record of key
+		Del(key string) error
+	}
+	// IdempotencyConfig idempotency config
+	IdempotencyConfig struct {
+		// Header the header name of idempotency key, default is Idempotency-Key
+		Header string
+		// Methods the methods of request, default is POST and PATCH
+		Methods []string
+		// TTL the ttl of record, default is 24 hours
+		TTL time.Duration
+		// Store idempotency store, default is local store
+		Store IdempotencyStore
+		// Limit the limit size of request body for hash, default is 50KB, no limit if it's < 0
+		Limit int
+		// Key returns the scope of idempotency key(e.g. the identity of caller),
+		// it's included in the key of record to avoid the records being shared by different callers
+		Key     func(c *elton.Context) string
+		Skipper elton.Skipper
+	}
+	localIdempotencyEntry struct {
+		record    *IdempotencyRecord
+		expiredAt time.Time
+	}
+	// LocalIdempotencyStore local idempotency store
+	LocalIdempotencyStore struct {
+		mutex     sync.Mutex
+		m         map[string]*localIdempotencyEntry
+		nextPrune time.Time
+		now       func() time.Time
+	}
+)
+
+var (
+	// ErrIdempotencyKeyInFlight the request of idempotency key is processing
+	ErrIdempotencyKeyInFlight = &hes.Error{
+		StatusCode: http.StatusConflict,
+		Message:    "request of idempotency key is processing",
+		Category:   ErrIdempotencyCategory,
+	}
+	// ErrIdempotencyKeyMismatch the idempotency key is reused with different body
+	ErrIdempotencyKeyMismatch = &hes.Error{
+		StatusCode: http.StatusUnprocessableEntity,
+		Message:    "idempotency key is reused with different body",
+		Category:   ErrIdempotencyCategory,
+	}
+)
+
+// NewLocalIdempotencyStore returns a new local idempotency store
+func NewLocalIdempotencyStore() *LocalIdempotencyStore {
+	return &LocalIdempotencyStore{
+		m:   make(map[string]*localIdempotencyEntry),
+		now: time.Now,
+	}
+}
+
+// get returns the entry if it's not expired, the lock should be held
+func (s *LocalIdempotencyStore) get(key string, now time.Time) *localIdempotencyEntry {
+	entry, ok := s.m[key]
+	if !ok || now.After(entry.expiredAt) {
+		return nil
+	}
+	return entry
+}
+
+// SetNX sets the record if the key is not exists or expired
+func (s *LocalIdempotencyStore) SetNX(key string, record *IdempotencyRecord, ttl time.Duration) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := s.now()
+	// 定期清除已过期的记录
+	if now.After(s.nextPrune) {
+		for k, entry := range s.m {
+			if now.After(entry.expiredAt) {
+				delete(s.m, k)
+			}
+		}
+		s.nextPrune = now.Add(ttl)
+	}
+	if s.get(key, now) != nil {
+		return false, nil
+	}
+	s.m[key] = &localIdempotencyEntry{
+		record:    record,
+		expiredAt: now.Add(ttl),
+	}
+	return true, nil
+}
+
+// Get returns the record of key
+func (s *LocalIdempotencyStore) Get(key string) (*IdempotencyRecord, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	entry := s.get(key, s.now())
+	if entry == nil {
+		return nil, nil
+	}
+	return entry.record, nil
+}
+
+// Set sets the record of key
+func (s *LocalIdempotencyStore) Set(key string, record *IdempotencyRecord, ttl time.Duration) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.m[key] = &localIdempotencyEntry{
+		record:    record,
+		expiredAt: s.now().Add(ttl),
+	}
+	return nil
+}
+
+// Del deletes the record of key
+func (s *LocalIdempotencyStore) Del(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.m, key)
+	return nil
+}
+
+// NewIdempotency returns a new idempotency middleware, it reads the idempotency key from header
+// and stores the first response(status, header and body) of the key, the response will be replayed
+// for retries with header Idempotent-Replayed: true.
+// It returns 409 if the request of the key is processing, and returns 422 if the key is reused with different body.
+// The record will be deleted if the request fails(error, 5xx or reader body), so the client can retry it.
+// It should be added before body parser middleware, because the hash is calculated from the original body.
+func NewIdempotency(config IdempotencyConfig) elton.Handler {
+	header := config.Header
+	if header == "" {
+		header = DefaultIdempotencyHeader
+	}
+	methods := config.Methods
+	if len(methods) == 0 {
+		methods = []string{
+			http.MethodPost,
+			http.MethodPatch,
+		}
+	}
+	ttl := config.TTL
+	if ttl <= 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+	store := config.Store
+	if store == nil {
+		store = NewLocalIdempotencyStore()
+	}
+	limit := defaultRequestBodyLimit
+	if config.Limit != 0 {
+		limit = config.Limit
+	}
+	skipper := config.Skipper
+	if skipper == nil {
+		skipper = elton.DefaultSkipper
+	}
+	wrapError := func(e error) error {
+		he, ok := e.(*hes.Error)
+		if !ok {
+			he = hes.Wrap(e)
+			he.StatusCode = http.StatusInternalServerError
+			he.Exception = true
+			he.Category = ErrIdempotencyCategory
+		}
+		return he
+	}
+	return func(c *elton.Context) (err error) {
+		if skipper(c) || !containsString(methods, c.Request.Method) {
+			return c.Next()
+		}
+		idempotencyKey := c.GetRequestHeader(header)
+		if idempotencyKey == "" {
+			return c.Next()
+		}
+		body, e := readSignatureBody(c, limit)
+		if e != nil {
+			he := hes.Wrap(e)
+			he.Category = ErrIdempotencyCategory
+			err = he
+			return
+		}
+		hash := sha256.Sum256(body)
+		bodyHash := hex.EncodeToString(hash[:])
+		key := c.Request.Method + " " + c.Request.URL.Path + " " + idempotencyKey
+		// 添加调用方的标识，避免不同的调用方使用相同的key时获取到其它调用方的响应
+		if config.Key != nil {
+			key = config.Key(c) + " " + key
+		}
+
+		success, e := store.SetNX(key, &IdempotencyRecord{
+			BodyHash: bodyHash,
+		}, ttl)
+		if e != nil {
+			err = wrapError(e)
+			return
+		}
+		if !success {
+			record, e := store.Get(key)
+			if e != nil {
+				err = wrapError(e)
+				return
+			}
+			// 记录刚好过期或被删除
+			if record == nil {
+				err = ErrIdempotencyKeyInFlight
+				return
+			}
+			if record.BodyHash != bodyHash {
+				err = ErrIdempotencyKeyMismatch
+				return
+			}
+			if !record.Completed || record.Response == nil {
+				err = ErrIdempotencyKeyInFlight
+				return
+			}
+			record.Response.applyTo(c)
+			c.SetHeader(HeaderIdempotentReplayed, "true")
+			return
+		}
+
+		completed := false
+		defer func() {
+			// 处理失败(包括panic)则删除记录，允许重试
+			if completed {
+				return
+			}
+			e := store.Del(key)
+			if e != nil && c.Elton() != nil {
+				c.Elton().EmitError(c, e)
+			}
+		}()
+		err = c.Next()
+		if err != nil || c.IsReaderBody() || c.StatusCode >= http.StatusInternalServerError {
+			return
+		}
+		e = store.Set(key, &IdempotencyRecord{
+			BodyHash:  bodyHash,
+			Completed: true,
+			Response:  newCachedResponse(c),
+		}, ttl)
+		// 请求已处理成功，保存失败则仅触发error事件
+		if e != nil {
+			if c.Elton() != nil {
+				c.Elton().EmitError(c, e)
+			}
+			return
+		}
+		completed = true
+		return
+	}
+}
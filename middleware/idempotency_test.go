@@ -0,0 +1,202 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vicanso/elton"
+	"github.com/vicanso/hes"
+)
+
+func TestLocalIdempotencyStore(t *testing.T) {
+	assert := assert.New(t)
+	clock := newFakeClock()
+	store := NewLocalIdempotencyStore()
+	store.now = clock.Now
+
+	success, err := store.SetNX("a", &IdempotencyRecord{}, time.Second)
+	assert.Nil(err)
+	assert.True(success)
+	success, err = store.SetNX("a", &IdempotencyRecord{}, time.Second)
+	assert.Nil(err)
+	assert.False(success)
+
+	assert.Nil(store.Set("a", &IdempotencyRecord{
+		Completed: true,
+	}, time.Second))
+	record, err := store.Get("a")
+	assert.Nil(err)
+	assert.True(record.Completed)
+
+	clock.Add(2 * time.Second)
+	record, err = store.Get("a")
+	assert.Nil(err)
+	assert.Nil(record)
+	success, _ = store.SetNX("a", &IdempotencyRecord{}, time.Second)
+	assert.True(success)
+
+	assert.Nil(store.Del("a"))
+	record, _ = store.Get("a")
+	assert.Nil(record)
+}
+
+func TestIdempotency(t *testing.T) {
+	assert := assert.New(t)
+
+	newContext := func(method, key, body string) *elton.Context {
+		req := httptest.NewRequest(method, "/orders", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(DefaultIdempotencyHeader, key)
+		}
+		return elton.NewContext(httptest.NewRecorder(), req)
+	}
+
+	t.Run("replay", func(t *testing.T) {
+		calls := 0
+		fn := NewIdempotency(IdempotencyConfig{})
+		for i := 0; i < 3; i++ {
+			c := newContext("POST", "1", `{"amount": 1}`)
+			c.Next = func() error {
+				calls++
+				c.Created(nil)
+				c.SetHeader("X-Order-Id", "1")
+				c.BodyBuffer = bytes.NewBufferString(`{"id": 1}`)
+				return nil
+			}
+			assert.Nil(fn(c))
+			assert.Equal(201, c.StatusCode)
+			assert.Equal("1", c.GetHeader("X-Order-Id"))
+			assert.Equal(`{"id": 1}`, c.BodyBuffer.String())
+			if i == 0 {
+				assert.Empty(c.GetHeader(HeaderIdempotentReplayed))
+			} else {
+				assert.Equal("true", c.GetHeader(HeaderIdempotentReplayed))
+			}
+		}
+		assert.Equal(1, calls)
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		fn := NewIdempotency(IdempotencyConfig{})
+		c := newContext("POST", "1", `{"amount": 1}`)
+		c.Next = func() error {
+			return nil
+		}
+		assert.Nil(fn(c))
+
+		c = newContext("POST", "1", `{"amount": 2}`)
+		assert.Equal(ErrIdempotencyKeyMismatch, fn(c))
+	})
+
+	t.Run("scope", func(t *testing.T) {
+		fn := NewIdempotency(IdempotencyConfig{
+			Key: func(c *elton.Context) string {
+				return c.GetString(DefaultAuthIdentityKey)
+			},
+		})
+		for _, user := range []string{"alice", "bob"} {
+			c := newContext("POST", "1", `{"user": "`+user+`"}`)
+			c.Set(DefaultAuthIdentityKey, user)
+			c.Next = func() error {
+				c.BodyBuffer = bytes.NewBufferString(user)
+				return nil
+			}
+			assert.Nil(fn(c))
+			assert.Equal(user, c.BodyBuffer.String())
+			assert.Empty(c.GetHeader(HeaderIdempotentReplayed))
+		}
+	})
+
+	t.Run("body limit", func(t *testing.T) {
+		fn := NewIdempotency(IdempotencyConfig{})
+		c := newContext("POST", "1", strings.Repeat("a", defaultRequestBodyLimit+1))
+		c.Next = func() error {
+			return nil
+		}
+		err := fn(c)
+		assert.NotNil(err)
+		assert.Equal(ErrIdempotencyCategory, err.(*hes.Error).Category)
+		assert.Contains(err.Error(), "request body is too large")
+
+		// 小于0则不限制
+		fn = NewIdempotency(IdempotencyConfig{
+			Limit: -1,
+		})
+		c = newContext("POST", "1", strings.Repeat("a", defaultRequestBodyLimit+1))
+		c.Next = func() error {
+			return nil
+		}
+		assert.Nil(fn(c))
+	})
+
+	t.Run("in flight", func(t *testing.T) {
+		fn := NewIdempotency(IdempotencyConfig{})
+		c := newContext("POST", "1", `{"amount": 1}`)
+		c.Next = func() error {
+			other := newContext("POST", "1", `{"amount": 1}`)
+			assert.Equal(ErrIdempotencyKeyInFlight, fn(other))
+			return nil
+		}
+		assert.Nil(fn(c))
+	})
+
+	t.Run("retry after error", func(t *testing.T) {
+		calls := 0
+		customErr := errors.New("abc")
+		fn := NewIdempotency(IdempotencyConfig{})
+		for i := 0; i < 2; i++ {
+			c := newContext("POST", "1", `{"amount": 1}`)
+			c.Next = func() error {
+				calls++
+				return customErr
+			}
+			assert.Equal(customErr, fn(c))
+		}
+		assert.Equal(2, calls)
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		calls := 0
+		fn := NewIdempotency(IdempotencyConfig{})
+		for _, c := range []*elton.Context{
+			newContext("POST", "", ""),
+			newContext("POST", "", ""),
+			newContext("PUT", "1", ""),
+			newContext("PUT", "1", ""),
+		} {
+			c.Next = func() error {
+				calls++
+				return nil
+			}
+			assert.Nil(fn(c))
+		}
+		assert.Equal(4, calls)
+	})
+}
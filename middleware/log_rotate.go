@@ -0,0 +1,399 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package middleware
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRotateLogBufferSize default buffer size of rotate log writer
+	DefaultRotateLogBufferSize = 32 * 1024
+	// rotateLogTimeLayout the time layout of rotated file
+	rotateLogTimeLayout = "20060102T150405.000"
+	rotateLogGzipExt    = ".gz"
+)
+
+var (
+	// ErrRotateLogFilenameRequired filename of rotate log is required
+	ErrRotateLogFilenameRequired = errors.New("filename of rotate log is required")
+)
+
+type (
+	// RotateLogWriterConfig rotate log writer config
+	RotateLogWriterConfig struct {
+		// Filename the file of log, the rotated file is renamed with the rotate time,
+		// e.g. access.log is renamed as access-20210101T000000.000.log
+		Filename string
+		// MaxSize rotates the file if its size exceeds max size, 0 means no limit
+		MaxSize int64
+		// Interval rotates the file by interval(aligned to utc time, e.g. 24h), 0 means no time-based rotation
+		Interval time.Duration
+		// Compress compresses the rotated file with gzip
+		Compress bool
+		// MaxBackups the max count of rotated files to retain, 0 means no limit
+		MaxBackups int
+		// MaxAge the max age of rotated files to retain, 0 means no limit
+		MaxAge time.Duration
+		// BufferSize the buffer size of write, default is 32KB
+		BufferSize int
+		// OnError the function of error in compressing and removing rotated files
+		OnError func(err error)
+	}
+	// RotateLogWriter the log writer with size or time-based rotation,
+	// the data is buffered, it should be flushed or closed.
+	RotateLogWriter struct {
+		config       RotateLogWriterConfig
+		mutex        sync.Mutex
+		file         *os.File
+		w            *bufio.Writer
+		size         int64
+		nextRotateAt time.Time
+		closed       bool
+		// processMutex 压缩与清除文件的锁
+		processMutex sync.Mutex
+		wg           sync.WaitGroup
+		now          func() time.Time
+	}
+	rotatedLogFile struct {
+		file      string
+		rotatedAt time.Time
+	}
+)
+
+// NewRotateLogWriter returns a new rotate log writer, the directory of file will be created if it's not exists
+func NewRotateLogWriter(config RotateLogWriterConfig) (*RotateLogWriter, error) {
+	if config.Filename == "" {
+		return nil, ErrRotateLogFilenameRequired
+	}
+	if config.BufferSize <= 0 {
+		config.BufferSize = DefaultRotateLogBufferSize
+	}
+	rw := &RotateLogWriter{
+		config: config,
+		now:    time.Now,
+	}
+	err := os.MkdirAll(filepath.Dir(config.Filename), 0755)
+	if err != nil {
+		return nil, err
+	}
+	err = rw.open()
+	if err != nil {
+		return nil, err
+	}
+	return rw, nil
+}
+
+// open opens the log file with append mode
+func (rw *RotateLogWriter) open() error {
+	file, err := os.OpenFile(rw.config.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	rw.file = file
+	rw.size = info.Size()
+	if rw.w == nil {
+		rw.w = bufio.NewWriterSize(file, rw.config.BufferSize)
+	} else {
+		rw.w.Reset(file)
+	}
+	return nil
+}
+
+func (rw *RotateLogWriter) getNextRotateAt(now time.Time) time.Time {
+	if rw.config.Interval <= 0 {
+		return time.Time{}
+	}
+	return now.Truncate(rw.config.Interval).Add(rw.config.Interval)
+}
+
+// splitFilename returns the prefix and ext of log file
+func (rw *RotateLogWriter) splitFilename() (string, string) {
+	ext := filepath.Ext(rw.config.Filename)
+	return strings.TrimSuffix(rw.config.Filename, ext) + "-", ext
+}
+
+// getBackupFile returns the file name of rotated file
+func (rw *RotateLogWriter) getBackupFile(now time.Time) string {
+	prefix, ext := rw.splitFilename()
+	t := now.UTC()
+	for {
+		file := prefix + t.Format(rotateLogTimeLayout) + ext
+		_, err := os.Stat(file)
+		_, gzErr := os.Stat(file + rotateLogGzipExt)
+		if os.IsNotExist(err) && os.IsNotExist(gzErr) {
+			return file
+		}
+		// 如果文件已存在，则增加1毫秒
+		t = t.Add(time.Millisecond)
+	}
+}
+
+// Write writes the data to log file, the file will be rotated before writing if necessary
+func (rw *RotateLogWriter) Write(p []byte) (int, error) {
+	rw.mutex.Lock()
+	defer rw.mutex.Unlock()
+	if rw.closed {
+		return 0, ErrLogWriterClosed
+	}
+	// 轮转失败时文件未能重新打开，则先重新打开
+	if rw.file == nil {
+		err := rw.open()
+		if err != nil {
+			return 0, err
+		}
+	}
+	now := rw.now()
+	if rw.nextRotateAt.IsZero() {
+		rw.nextRotateAt = rw.getNextRotateAt(now)
+	}
+	shouldRotate := !rw.nextRotateAt.IsZero() && !now.Before(rw.nextRotateAt)
+	if rw.config.MaxSize > 0 && rw.size > 0 && rw.size+int64(len(p)) > rw.config.MaxSize {
+		shouldRotate = true
+	}
+	// 空文件无需轮转
+	if shouldRotate && rw.size == 0 {
+		shouldRotate = false
+		rw.nextRotateAt = rw.getNextRotateAt(now)
+	}
+	if shouldRotate {
+		err := rw.rotate(now)
+		if err != nil {
+			// 已重新打开文件则继续写入，避免丢失日志
+			if rw.file == nil {
+				return 0, err
+			}
+			rw.emitError(err)
+		}
+	}
+	n, err := rw.w.Write(p)
+	rw.size += int64(n)
+	return n, err
+}
+
+// Rotate rotates the log file
+func (rw *RotateLogWriter) Rotate() error {
+	rw.mutex.Lock()
+	defer rw.mutex.Unlock()
+	if rw.closed {
+		return ErrLogWriterClosed
+	}
+	if rw.file == nil {
+		err := rw.open()
+		if err != nil {
+			return err
+		}
+	}
+	return rw.rotate(rw.now())
+}
+
+func (rw *RotateLogWriter) rotate(now time.Time) error {
+	err := rw.w.Flush()
+	if err != nil {
+		return err
+	}
+	err = rw.file.Close()
+	rw.file = nil
+	if err != nil {
+		// 关闭失败也重新打开，保证后续可写入
+		return rw.reopen(err)
+	}
+	backupFile := rw.getBackupFile(now)
+	err = os.Rename(rw.config.Filename, backupFile)
+	if err != nil {
+		return rw.reopen(err)
+	}
+	err = rw.open()
+	if err != nil {
+		return err
+	}
+	rw.nextRotateAt = rw.getNextRotateAt(now)
+	// 压缩与清除文件在goroutine中处理，避免阻塞写入
+	rw.wg.Add(1)
+	go func() {
+		defer rw.wg.Done()
+		rw.process(backupFile, now)
+	}()
+	return nil
+}
+
+// reopen reopens the log file with append mode after rotation failed,
+// the error of rotation is returned.
+func (rw *RotateLogWriter) reopen(err error) error {
+	// 如果打开失败，则在下次写入时再重新打开
+	_ = rw.open()
+	return err
+}
+
+func (rw *RotateLogWriter) emitError(err error) {
+	if err != nil && rw.config.OnError != nil {
+		rw.config.OnError(err)
+	}
+}
+
+// process compresses the rotated file and removes the expired files
+func (rw *RotateLogWriter) process(backupFile string, now time.Time) {
+	rw.processMutex.Lock()
+	defer rw.processMutex.Unlock()
+	if rw.config.Compress {
+		rw.emitError(gzipLogFile(backupFile))
+	}
+	rw.emitError(rw.removeExpiredFiles(now))
+}
+
+// gzipLogFile compresses the file to file.gz, and then removes the file
+func gzipLogFile(file string) (err error) {
+	src, err := os.Open(file)
+	if err != nil {
+		return
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(file+rotateLogGzipExt, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return
+	}
+	defer func() {
+		// 压缩失败则删除压缩文件
+		if err != nil {
+			_ = os.Remove(dst.Name())
+		}
+	}()
+	w := gzip.NewWriter(dst)
+	_, err = io.Copy(w, src)
+	if err == nil {
+		err = w.Close()
+	}
+	closeErr := dst.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return
+	}
+	return os.Remove(file)
+}
+
+// listRotatedFiles returns the rotated files, the newest is first
+func (rw *RotateLogWriter) listRotatedFiles() ([]*rotatedLogFile, error) {
+	prefix, ext := rw.splitFilename()
+	entries, err := os.ReadDir(filepath.Dir(rw.config.Filename))
+	if err != nil {
+		return nil, err
+	}
+	basePrefix := filepath.Base(prefix)
+	files := make([]*rotatedLogFile, 0)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, basePrefix) {
+			continue
+		}
+		value := strings.TrimSuffix(strings.TrimPrefix(name, basePrefix), rotateLogGzipExt)
+		if !strings.HasSuffix(value, ext) {
+			continue
+		}
+		rotatedAt, err := time.Parse(rotateLogTimeLayout, strings.TrimSuffix(value, ext))
+		// 非轮转的日志文件
+		if err != nil {
+			continue
+		}
+		files = append(files, &rotatedLogFile{
+			file:      filepath.Join(filepath.Dir(prefix), name),
+			rotatedAt: rotatedAt,
+		})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].rotatedAt.After(files[j].rotatedAt)
+	})
+	return files, nil
+}
+
+// removeExpiredFiles removes the files which exceed max backups or max age
+func (rw *RotateLogWriter) removeExpiredFiles(now time.Time) error {
+	if rw.config.MaxBackups <= 0 && rw.config.MaxAge <= 0 {
+		return nil
+	}
+	files, err := rw.listRotatedFiles()
+	if err != nil {
+		return err
+	}
+	var removeErr error
+	for index, item := range files {
+		expired := rw.config.MaxBackups > 0 && index >= rw.config.MaxBackups
+		if rw.config.MaxAge > 0 && now.Sub(item.rotatedAt) > rw.config.MaxAge {
+			expired = true
+		}
+		if !expired {
+			continue
+		}
+		err := os.Remove(item.file)
+		if err != nil && removeErr == nil {
+			removeErr = err
+		}
+	}
+	return removeErr
+}
+
+// Flush flushes the buffered data to file
+func (rw *RotateLogWriter) Flush() error {
+	rw.mutex.Lock()
+	defer rw.mutex.Unlock()
+	if rw.closed {
+		return nil
+	}
+	return rw.w.Flush()
+}
+
+// Close flushes the buffered data and closes the file,
+// it will wait for the rotated files processed.
+func (rw *RotateLogWriter) Close() error {
+	rw.mutex.Lock()
+	if rw.closed {
+		rw.mutex.Unlock()
+		return nil
+	}
+	rw.closed = true
+	err := rw.w.Flush()
+	var closeErr error
+	if rw.file != nil {
+		closeErr = rw.file.Close()
+	}
+	rw.mutex.Unlock()
+	rw.wg.Wait()
+	if err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,264 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package middleware
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vicanso/elton"
+)
+
+func readLogDir(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	files := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		files = append(files, entry.Name())
+	}
+	sort.Strings(files)
+	return files
+}
+
+func readLogFile(t *testing.T, file string) string {
+	buf, err := ioutil.ReadFile(file)
+	assert.Nil(t, err)
+	return string(buf)
+}
+
+func TestNewRotateLogWriter(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := NewRotateLogWriter(RotateLogWriterConfig{})
+	assert.Equal(ErrRotateLogFilenameRequired, err)
+
+	// 目录不存在则创建，已有的文件则追加
+	file := filepath.Join(t.TempDir(), "logs", "access.log")
+	rw, err := NewRotateLogWriter(RotateLogWriterConfig{
+		Filename: file,
+	})
+	assert.Nil(err)
+	_, err = rw.Write([]byte("a\n"))
+	assert.Nil(err)
+	// 数据已缓存
+	assert.Equal("", readLogFile(t, file))
+	assert.Nil(rw.Flush())
+	assert.Equal("a\n", readLogFile(t, file))
+	assert.Nil(rw.Close())
+	_, err = rw.Write([]byte("b\n"))
+	assert.Equal(ErrLogWriterClosed, err)
+	assert.Equal(ErrLogWriterClosed, rw.Rotate())
+
+	rw, err = NewRotateLogWriter(RotateLogWriterConfig{
+		Filename: file,
+	})
+	assert.Nil(err)
+	assert.Equal(int64(2), rw.size)
+	_, _ = rw.Write([]byte("b\n"))
+	assert.Nil(rw.Close())
+	assert.Equal("a\nb\n", readLogFile(t, file))
+}
+
+func TestRotateLogWriterSize(t *testing.T) {
+	assert := assert.New(t)
+	clock := newFakeClock()
+	dir := t.TempDir()
+	rw, err := NewRotateLogWriter(RotateLogWriterConfig{
+		Filename: filepath.Join(dir, "access.log"),
+		MaxSize:  10,
+	})
+	assert.Nil(err)
+	rw.now = clock.Now
+
+	_, err = rw.Write([]byte("12345\n"))
+	assert.Nil(err)
+	// 超过限制，轮转后写入
+	_, err = rw.Write([]byte("67890\n"))
+	assert.Nil(err)
+	// 同一时间轮转，文件名增加1毫秒
+	_, err = rw.Write([]byte("abcde\n"))
+	assert.Nil(err)
+	assert.Nil(rw.Close())
+
+	assert.Equal([]string{
+		"access-20210101T000000.000.log",
+		"access-20210101T000000.001.log",
+		"access.log",
+	}, readLogDir(t, dir))
+	assert.Equal("12345\n", readLogFile(t, filepath.Join(dir, "access-20210101T000000.000.log")))
+	assert.Equal("67890\n", readLogFile(t, filepath.Join(dir, "access-20210101T000000.001.log")))
+	assert.Equal("abcde\n", readLogFile(t, filepath.Join(dir, "access.log")))
+}
+
+func TestRotateLogWriterRotateFail(t *testing.T) {
+	assert := assert.New(t)
+	dir := filepath.Join(t.TempDir(), "logs")
+	file := filepath.Join(dir, "access.log")
+	var errs []error
+	rw, err := NewRotateLogWriter(RotateLogWriterConfig{
+		Filename: file,
+		MaxSize:  10,
+		OnError: func(err error) {
+			errs = append(errs, err)
+		},
+	})
+	assert.Nil(err)
+
+	// 文件被删除，重命名失败后重新打开文件
+	_, err = rw.Write([]byte("12345\n"))
+	assert.Nil(err)
+	assert.Nil(os.Remove(file))
+	assert.NotNil(rw.Rotate())
+	_, err = rw.Write([]byte("a\n"))
+	assert.Nil(err)
+	assert.Nil(rw.Flush())
+	assert.Equal("a\n", readLogFile(t, file))
+
+	// 写入时轮转失败，触发出错事件并继续写入
+	assert.Nil(os.Remove(file))
+	_, err = rw.Write([]byte("1234567890\n"))
+	assert.Nil(err)
+	assert.Equal(1, len(errs))
+	assert.Nil(rw.Flush())
+	assert.Equal("1234567890\n", readLogFile(t, file))
+
+	// 目录被删除，重新打开失败，在目录恢复后可继续写入
+	assert.Nil(os.RemoveAll(dir))
+	assert.NotNil(rw.Rotate())
+	_, err = rw.Write([]byte("b\n"))
+	assert.NotNil(err)
+	assert.Nil(os.MkdirAll(dir, 0755))
+	_, err = rw.Write([]byte("c\n"))
+	assert.Nil(err)
+	assert.Nil(rw.Close())
+	assert.Equal("c\n", readLogFile(t, file))
+}
+
+func TestRotateLogWriterInterval(t *testing.T) {
+	assert := assert.New(t)
+	clock := newFakeClock()
+	clock.Add(30 * time.Minute)
+	dir := t.TempDir()
+	rw, err := NewRotateLogWriter(RotateLogWriterConfig{
+		Filename: filepath.Join(dir, "access.log"),
+		Interval: time.Hour,
+	})
+	assert.Nil(err)
+	rw.now = clock.Now
+
+	_, _ = rw.Write([]byte("a\n"))
+	clock.Add(20 * time.Minute)
+	_, _ = rw.Write([]byte("b\n"))
+	// 01:00后写入则轮转
+	clock.Add(20 * time.Minute)
+	_, _ = rw.Write([]byte("c\n"))
+	assert.Equal(time.Unix(1609459200, 0).Add(2*time.Hour), rw.nextRotateAt)
+	assert.Nil(rw.Close())
+
+	assert.Equal([]string{
+		"access-20210101T011000.000.log",
+		"access.log",
+	}, readLogDir(t, dir))
+	assert.Equal("a\nb\n", readLogFile(t, filepath.Join(dir, "access-20210101T011000.000.log")))
+	assert.Equal("c\n", readLogFile(t, filepath.Join(dir, "access.log")))
+}
+
+func TestRotateLogWriterRetention(t *testing.T) {
+	assert := assert.New(t)
+	clock := newFakeClock()
+	dir := t.TempDir()
+	var processErr error
+	rw, err := NewRotateLogWriter(RotateLogWriterConfig{
+		Filename:   filepath.Join(dir, "access.log"),
+		Compress:   true,
+		MaxBackups: 2,
+		MaxAge:     90 * time.Minute,
+		OnError: func(err error) {
+			processErr = err
+		},
+	})
+	assert.Nil(err)
+	rw.now = clock.Now
+	// 非轮转的文件不删除
+	err = ioutil.WriteFile(filepath.Join(dir, "access-backup.log"), []byte("backup"), 0644)
+	assert.Nil(err)
+
+	for _, str := range []string{"a", "b", "c"} {
+		_, _ = rw.Write([]byte(str))
+		assert.Nil(rw.Rotate())
+		rw.wg.Wait()
+		clock.Add(time.Hour)
+	}
+	assert.Nil(processErr)
+	// 仅保留两个
+	assert.Equal([]string{
+		"access-20210101T010000.000.log.gz",
+		"access-20210101T020000.000.log.gz",
+		"access-backup.log",
+		"access.log",
+	}, readLogDir(t, dir))
+	f, err := os.Open(filepath.Join(dir, "access-20210101T020000.000.log.gz"))
+	assert.Nil(err)
+	defer f.Close()
+	r, err := gzip.NewReader(f)
+	assert.Nil(err)
+	buf, _ := ioutil.ReadAll(r)
+	assert.Equal("c", string(buf))
+
+	// 超过90分钟的删除
+	_, _ = rw.Write([]byte("d"))
+	assert.Nil(rw.Rotate())
+	assert.Nil(rw.Close())
+	assert.Equal([]string{
+		"access-20210101T020000.000.log.gz",
+		"access-20210101T030000.000.log.gz",
+		"access-backup.log",
+		"access.log",
+	}, readLogDir(t, dir))
+}
+
+func TestRotateLogWriterGracefulClose(t *testing.T) {
+	assert := assert.New(t)
+	file := filepath.Join(t.TempDir(), "access.log")
+	rw, err := NewRotateLogWriter(RotateLogWriterConfig{
+		Filename: file,
+	})
+	assert.Nil(err)
+	aw := NewAsyncLogWriter(rw, AsyncLogWriterConfig{})
+
+	e := elton.New()
+	e.OnClose(aw.Close)
+	for i := 0; i < 100; i++ {
+		_, err = aw.Write([]byte("a\n"))
+		assert.Nil(err)
+	}
+	err = e.GracefulClose(time.Millisecond)
+	assert.Nil(err)
+	assert.Equal(200, len(readLogFile(t, file)))
+	assert.True(rw.closed)
+}
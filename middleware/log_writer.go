@@ -0,0 +1,151 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package middleware
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// DefaultAsyncLogQueueSize default queue size of async log writer
+	DefaultAsyncLogQueueSize = 1024
+)
+
+var (
+	// ErrLogWriterClosed log writer is closed
+	ErrLogWriterClosed = errors.New("log writer is closed")
+)
+
+type (
+	// AsyncLogWriterConfig async log writer config
+	AsyncLogWriterConfig struct {
+		// QueueSize the max count of records in queue, default is 1024.
+		// The record will be dropped if the queue is full.
+		QueueSize int
+		// OnError the function of write error
+		OnError func(err error)
+	}
+	// AsyncLogWriter the async log writer, it writes the records to writer in a goroutine,
+	// and the writer will be flushed when the queue is empty if it supports Flush.
+	AsyncLogWriter struct {
+		w       io.Writer
+		config  AsyncLogWriterConfig
+		mutex   sync.RWMutex
+		closed  bool
+		queue   chan []byte
+		done    chan struct{}
+		dropped uint64
+		written uint64
+	}
+	logFlusher interface {
+		Flush() error
+	}
+)
+
+// NewAsyncLogWriter returns a new async log writer
+func NewAsyncLogWriter(w io.Writer, config AsyncLogWriterConfig) *AsyncLogWriter {
+	queueSize := config.QueueSize
+	if queueSize <= 0 {
+		queueSize = DefaultAsyncLogQueueSize
+	}
+	aw := &AsyncLogWriter{
+		w:      w,
+		config: config,
+		queue:  make(chan []byte, queueSize),
+		done:   make(chan struct{}),
+	}
+	go aw.run()
+	return aw
+}
+
+func (aw *AsyncLogWriter) run() {
+	defer close(aw.done)
+	flusher, _ := aw.w.(logFlusher)
+	for buf := range aw.queue {
+		_, err := aw.w.Write(buf)
+		if err != nil {
+			aw.emitError(err)
+		} else {
+			atomic.AddUint64(&aw.written, 1)
+		}
+		// 队列已无数据时刷新
+		if flusher != nil && len(aw.queue) == 0 {
+			aw.emitError(flusher.Flush())
+		}
+	}
+}
+
+func (aw *AsyncLogWriter) emitError(err error) {
+	if err != nil && aw.config.OnError != nil {
+		aw.config.OnError(err)
+	}
+}
+
+// Write adds the record to queue, it will not be blocked,
+// and the record will be dropped if the queue is full.
+func (aw *AsyncLogWriter) Write(p []byte) (int, error) {
+	aw.mutex.RLock()
+	defer aw.mutex.RUnlock()
+	if aw.closed {
+		return 0, ErrLogWriterClosed
+	}
+	// 复制数据，避免调用者修改
+	buf := make([]byte, len(p))
+	copy(buf, p)
+	select {
+	case aw.queue <- buf:
+	default:
+		atomic.AddUint64(&aw.dropped, 1)
+	}
+	return len(p), nil
+}
+
+// Dropped returns the count of dropped records
+func (aw *AsyncLogWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&aw.dropped)
+}
+
+// Written returns the count of written records
+func (aw *AsyncLogWriter) Written() uint64 {
+	return atomic.LoadUint64(&aw.written)
+}
+
+// Close closes the writer, it will wait for all records in queue written,
+// and the writer will be closed if it's io.Closer.
+func (aw *AsyncLogWriter) Close() error {
+	aw.mutex.Lock()
+	if aw.closed {
+		aw.mutex.Unlock()
+		return nil
+	}
+	aw.closed = true
+	close(aw.queue)
+	aw.mutex.Unlock()
+	<-aw.done
+	if closer, ok := aw.w.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type blockWriter struct {
+	mutex   sync.Mutex
+	buf     bytes.Buffer
+	wait    chan struct{}
+	closed  bool
+	failed  bool
+	written int
+}
+
+func (bw *blockWriter) Write(p []byte) (int, error) {
+	if bw.wait != nil {
+		<-bw.wait
+	}
+	bw.mutex.Lock()
+	defer bw.mutex.Unlock()
+	if bw.failed {
+		return 0, errors.New("write fail")
+	}
+	bw.written++
+	return bw.buf.Write(p)
+}
+
+func (bw *blockWriter) Close() error {
+	bw.closed = true
+	return nil
+}
+
+func TestAsyncLogWriter(t *testing.T) {
+	assert := assert.New(t)
+
+	w := &blockWriter{}
+	aw := NewAsyncLogWriter(w, AsyncLogWriterConfig{})
+	for _, str := range []string{"a\n", "b\n", "c\n"} {
+		n, err := aw.Write([]byte(str))
+		assert.Nil(err)
+		assert.Equal(2, n)
+	}
+	err := aw.Close()
+	assert.Nil(err)
+	assert.True(w.closed)
+	assert.Equal("a\nb\nc\n", w.buf.String())
+	assert.Equal(uint64(3), aw.Written())
+	assert.Equal(uint64(0), aw.Dropped())
+	// 已关闭
+	_, err = aw.Write([]byte("d\n"))
+	assert.Equal(ErrLogWriterClosed, err)
+	assert.Nil(aw.Close())
+}
+
+func TestAsyncLogWriterDrop(t *testing.T) {
+	assert := assert.New(t)
+
+	w := &blockWriter{
+		wait: make(chan struct{}),
+	}
+	aw := NewAsyncLogWriter(w, AsyncLogWriterConfig{
+		QueueSize: 2,
+	})
+	// 第一条记录由goroutine读取后阻塞，队列最多两条记录
+	for i := 0; i < 10; i++ {
+		_, err := aw.Write([]byte("a"))
+		assert.Nil(err)
+	}
+	close(w.wait)
+	err := aw.Close()
+	assert.Nil(err)
+	assert.Equal(aw.Written()+aw.Dropped(), uint64(10))
+	assert.True(aw.Dropped() >= 7)
+	assert.Equal(int(aw.Written()), w.written)
+}
+
+func TestAsyncLogWriterError(t *testing.T) {
+	assert := assert.New(t)
+
+	var writeErr error
+	w := &blockWriter{
+		failed: true,
+	}
+	aw := NewAsyncLogWriter(w, AsyncLogWriterConfig{
+		OnError: func(err error) {
+			writeErr = err
+		},
+	})
+	_, err := aw.Write([]byte("a"))
+	assert.Nil(err)
+	err = aw.Close()
+	assert.Nil(err)
+	assert.Equal("write fail", writeErr.Error())
+	assert.Equal(uint64(0), aw.Written())
+}
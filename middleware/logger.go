@@ -24,6 +24,7 @@ package middleware
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"regexp"
@@ -81,6 +82,11 @@ const (
 	LoggerShort = `{remote} {method} {uri} {proto} {status} {size-human} - {latency-ms} ms`
 	// LoggerTiny tiny log format
 	LoggerTiny = `{method} {url} {status} {size-human} - {latency-ms} ms`
+
+	// LoggerModeJSON structured log of json
+	LoggerModeJSON = "json"
+	// LoggerModeLogfmt structured log of logfmt
+	LoggerModeLogfmt = "logfmt"
 )
 
 type (
@@ -98,6 +104,17 @@ type (
 		Format      string
 		OnLog       OnLog
 		Skipper     elton.Skipper
+		// Mode the mode of structured log(json or logfmt), the format will be ignored if it's set
+		Mode string
+		// RequestHeaders the request headers of structured log
+		RequestHeaders []string
+		// ResponseHeaders the response headers of structured log
+		ResponseHeaders []string
+		// ContextKeys the keys of context(c.Get) of structured log
+		ContextKeys []string
+		// Writer the writer of structured log, each record ends with a newline.
+		// Suggest to use async log writer to avoid blocking the request.
+		Writer io.Writer
 	}
 )
 
@@ -272,17 +289,9 @@ func formatLog(c *elton.Context, tags []*LoggerTag, startedAt time.Time, default
 		case payloadSizeHuman:
 			return getHumanReadableSize(len(c.RequestBody))
 		case size:
-			bodyBuf := c.BodyBuffer
-			if bodyBuf == nil {
-				return "0"
-			}
-			return strconv.Itoa(bodyBuf.Len())
+			return strconv.Itoa(c.ResponseSize())
 		case sizeHuman:
-			bodyBuf := c.BodyBuffer
-			if bodyBuf == nil {
-				return "0B"
-			}
-			return getHumanReadableSize(bodyBuf.Len())
+			return getHumanReadableSize(c.ResponseSize())
 		case latency:
 			return time.Since(startedAt).String()
 		case latencyMs:
@@ -307,7 +316,11 @@ func formatLog(c *elton.Context, tags []*LoggerTag, startedAt time.Time, default
 // NewLogger returns a new logger middleware, it can log the field of querystring, header, cookie and context.
 // It will throw a panic if the Format is empty.
 // It will throw a panic if the OnLog function is nil.
+// For structured log(json or logfmt), it will throw a panic if both Writer and OnLog are nil.
 func NewLogger(config LoggerConfig) elton.Handler {
+	if config.Mode != "" {
+		return newStructuredLogger(config)
+	}
 	if config.Format == "" {
 		panic("logger require format")
 	}
@@ -0,0 +1,222 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vicanso/elton"
+)
+
+type (
+	// loggerField the field of structured log
+	loggerField struct {
+		key   string
+		value interface{}
+	}
+)
+
+// getLoggerHeaders returns the values of headers, the empty value is ignored
+func getLoggerHeaders(header http.Header, keys []string) map[string]interface{} {
+	m := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		value := header.Get(key)
+		if value != "" {
+			m[key] = value
+		}
+	}
+	return m
+}
+
+// newLoggerFields returns the fields of structured log
+func newLoggerFields(c *elton.Context, config LoggerConfig, startedAt time.Time) []*loggerField {
+	statusCode := c.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	// 耗时(毫秒)，保留三位小数
+	latency := math.Round(float64(time.Since(startedAt))/float64(time.Microsecond)) / 1000
+	fields := []*loggerField{
+		{"time", time.Now().Format("2006-01-02T15:04:05.999Z07:00")},
+		{"id", c.ID},
+		{"ip", c.ClientIP()},
+		{"method", c.Request.Method},
+		{"route", c.Route},
+		{"uri", c.Request.RequestURI},
+		{"status", statusCode},
+		{"latency", latency},
+		{"size", c.ResponseSize()},
+		{"payloadSize", len(c.RequestBody)},
+	}
+	if len(config.RequestHeaders) != 0 {
+		fields = append(fields, &loggerField{
+			requestHeader,
+			getLoggerHeaders(c.Request.Header, config.RequestHeaders),
+		})
+	}
+	if len(config.ResponseHeaders) != 0 {
+		fields = append(fields, &loggerField{
+			responseHeader,
+			getLoggerHeaders(c.Header(), config.ResponseHeaders),
+		})
+	}
+	if len(config.ContextKeys) != 0 {
+		m := make(map[string]interface{}, len(config.ContextKeys))
+		for _, key := range config.ContextKeys {
+			value, exists := c.Get(key)
+			if exists {
+				m[key] = value
+			}
+		}
+		fields = append(fields, &loggerField{
+			context,
+			m,
+		})
+	}
+	return fields
+}
+
+// marshalLoggerJSON marshals the fields as json object with the same order
+func marshalLoggerJSON(fields []*loggerField) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	buf.WriteByte('{')
+	for index, field := range fields {
+		if index != 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(field.key)
+		buf.Write(key)
+		buf.WriteByte(':')
+		value, err := json.Marshal(field.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// formatLogfmtValue formats the value of logfmt, the string value will be quoted if necessary
+func formatLogfmtValue(value interface{}) string {
+	var str string
+	switch v := value.(type) {
+	case string:
+		str = v
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case fmt.Stringer:
+		str = v.String()
+	default:
+		buf, err := json.Marshal(v)
+		if err != nil {
+			str = fmt.Sprintf("%v", v)
+		} else {
+			str = string(buf)
+		}
+	}
+	if str == "" || strings.ContainsAny(str, " =\"\t\r\n") {
+		return strconv.Quote(str)
+	}
+	return str
+}
+
+// marshalLoggerLogfmt marshals the fields as logfmt, the key of map field is prefixed with the field's key
+func marshalLoggerLogfmt(fields []*loggerField) []byte {
+	arr := make([]string, 0, len(fields))
+	for _, field := range fields {
+		m, ok := field.value.(map[string]interface{})
+		if !ok {
+			arr = append(arr, field.key+"="+formatLogfmtValue(field.value))
+			continue
+		}
+		keys := make([]string, 0, len(m))
+		for key := range m {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			arr = append(arr, field.key+"."+key+"="+formatLogfmtValue(m[key]))
+		}
+	}
+	return []byte(strings.Join(arr, " "))
+}
+
+// newStructuredLogger returns a new logger middleware of structured log
+func newStructuredLogger(config LoggerConfig) elton.Handler {
+	if config.Mode != LoggerModeJSON && config.Mode != LoggerModeLogfmt {
+		panic("logger mode should be json or logfmt")
+	}
+	if config.Writer == nil && config.OnLog == nil {
+		panic("logger require writer or on log function")
+	}
+	skipper := config.Skipper
+	if skipper == nil {
+		skipper = elton.DefaultSkipper
+	}
+	return func(c *elton.Context) (err error) {
+		if skipper(c) {
+			return c.Next()
+		}
+		startedAt := time.Now()
+		err = c.Next()
+		fields := newLoggerFields(c, config, startedAt)
+		var buf []byte
+		if config.Mode == LoggerModeJSON {
+			var e error
+			buf, e = marshalLoggerJSON(fields)
+			// 转换失败则忽略
+			if e != nil {
+				if c.Elton() != nil {
+					c.Elton().EmitError(c, e)
+				}
+				return
+			}
+		} else {
+			buf = marshalLoggerLogfmt(fields)
+		}
+		if config.OnLog != nil {
+			config.OnLog(string(buf), c)
+		}
+		if config.Writer != nil {
+			_, e := config.Writer.Write(append(buf, '\n'))
+			if e != nil && c.Elton() != nil {
+				c.Elton().EmitError(c, e)
+			}
+		}
+		return
+	}
+}
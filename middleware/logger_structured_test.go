@@ -0,0 +1,145 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vicanso/elton"
+)
+
+func TestMarshalLogger(t *testing.T) {
+	assert := assert.New(t)
+
+	fields := []*loggerField{
+		{"method", "GET"},
+		{"uri", "/users?q=a b"},
+		{"status", 200},
+		{"latency", 1.25},
+		{"empty", ""},
+		{"requestHeader", map[string]interface{}{
+			"X-Id":       "1",
+			"User-Agent": "go client",
+		}},
+		{"context", map[string]interface{}{
+			"tags": []string{"a"},
+		}},
+	}
+	buf, err := marshalLoggerJSON(fields)
+	assert.Nil(err)
+	assert.Equal(`{"method":"GET","uri":"/users?q=a b","status":200,"latency":1.25,"empty":"","requestHeader":{"User-Agent":"go client","X-Id":"1"},"context":{"tags":["a"]}}`, string(buf))
+
+	assert.Equal(`method=GET uri="/users?q=a b" status=200 latency=1.25 empty="" requestHeader.User-Agent="go client" requestHeader.X-Id=1 context.tags="[\"a\"]"`, string(marshalLoggerLogfmt(fields)))
+}
+
+func TestStructuredLogger(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.PanicsWithValue("logger mode should be json or logfmt", func() {
+		NewLogger(LoggerConfig{
+			Mode: "xml",
+		})
+	})
+	assert.PanicsWithValue("logger require writer or on log function", func() {
+		NewLogger(LoggerConfig{
+			Mode: LoggerModeJSON,
+		})
+	})
+
+	newContext := func() *elton.Context {
+		req := httptest.NewRequest("POST", "/users/1?type=vip", nil)
+		req.Header.Set("User-Agent", "test-agent")
+		c := elton.NewContext(httptest.NewRecorder(), req)
+		c.ID = "abcd"
+		c.Route = "/users/{id}"
+		c.RequestBody = []byte("abc")
+		c.Next = func() error {
+			time.Sleep(time.Millisecond)
+			c.Set("account", "tree")
+			c.Set("amount", 10)
+			c.SetHeader("X-Response-Id", "1")
+			c.StatusCode = 201
+			c.BodyBuffer = bytes.NewBufferString("abcd")
+			return nil
+		}
+		return c
+	}
+
+	buf := new(bytes.Buffer)
+	fn := NewLogger(LoggerConfig{
+		Mode:            LoggerModeJSON,
+		RequestHeaders:  []string{"User-Agent", "X-Empty"},
+		ResponseHeaders: []string{"X-Response-Id"},
+		ContextKeys:     []string{"account", "amount", "not-exists"},
+		Writer:          buf,
+	})
+	err := fn(newContext())
+	assert.Nil(err)
+	assert.True(strings.HasSuffix(buf.String(), "}\n"))
+	record := make(map[string]interface{})
+	err = json.Unmarshal(buf.Bytes(), &record)
+	assert.Nil(err)
+	assert.NotEmpty(record["time"])
+	assert.True(record["latency"].(float64) >= 1)
+	delete(record, "time")
+	delete(record, "latency")
+	assert.Equal(map[string]interface{}{
+		"id":          "abcd",
+		"ip":          "192.0.2.1",
+		"method":      "POST",
+		"route":       "/users/{id}",
+		"uri":         "/users/1?type=vip",
+		"status":      float64(201),
+		"size":        float64(4),
+		"payloadSize": float64(3),
+		"requestHeader": map[string]interface{}{
+			"User-Agent": "test-agent",
+		},
+		"responseHeader": map[string]interface{}{
+			"X-Response-Id": "1",
+		},
+		"context": map[string]interface{}{
+			"account": "tree",
+			"amount":  float64(10),
+		},
+	}, record)
+
+	var log string
+	fn = NewLogger(LoggerConfig{
+		Mode:        LoggerModeLogfmt,
+		ContextKeys: []string{"account"},
+		OnLog: func(str string, _ *elton.Context) {
+			log = str
+		},
+	})
+	err = fn(newContext())
+	assert.Nil(err)
+	assert.True(strings.HasPrefix(log, "time="))
+	assert.Contains(log, " id=abcd ip=192.0.2.1 method=POST route=/users/{id} uri=\"/users/1?type=vip\" status=201 latency=")
+	assert.True(strings.HasSuffix(log, " size=4 payloadSize=3 context.account=tree"))
+}
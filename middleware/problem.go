@@ -0,0 +1,182 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/vicanso/elton"
+	"github.com/vicanso/hes"
+)
+
+const (
+	// MIMEApplicationProblemJSON problem details of RFC 7807
+	MIMEApplicationProblemJSON = "application/problem+json"
+	// DefaultProblemType default problem type of RFC 7807
+	DefaultProblemType = "about:blank"
+)
+
+type (
+	// ProblemType the problem type of RFC 7807
+	ProblemType struct {
+		// Type the uri of problem type
+		Type string
+		// Title the short summary of problem type
+		Title string
+		// StatusCode the status code of problem type, it will use the status code of error if it's 0
+		StatusCode int
+	}
+	// Problem the problem details of RFC 7807
+	Problem struct {
+		Type     string `json:"type"`
+		Title    string `json:"title"`
+		Status   int    `json:"status"`
+		Detail   string `json:"detail,omitempty"`
+		Instance string `json:"instance,omitempty"`
+		// Extensions the extension members of problem
+		Extensions map[string]interface{} `json:"-"`
+	}
+	problemMatcher struct {
+		match       elton.ErrorMatcher
+		problemType *ProblemType
+	}
+	// ProblemRegistry the registry of problem types,
+	// it maps the go error and the category of hes.Error to problem type.
+	ProblemRegistry struct {
+		mutex      sync.RWMutex
+		matchers   []*problemMatcher
+		categories map[string]*ProblemType
+	}
+)
+
+// NewProblemRegistry returns a new problem registry
+func NewProblemRegistry() *ProblemRegistry {
+	return &ProblemRegistry{
+		categories: make(map[string]*ProblemType),
+	}
+}
+
+func (r *ProblemRegistry) addMatcher(match elton.ErrorMatcher, pt ProblemType) *ProblemRegistry {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.matchers = append(r.matchers, &problemMatcher{
+		match:       match,
+		problemType: &pt,
+	})
+	return r
+}
+
+// RegisterError registers the problem type for the error, it's matched by errors.Is
+func (r *ProblemRegistry) RegisterError(target error, pt ProblemType) *ProblemRegistry {
+	return r.addMatcher(elton.NewErrorMatcher(target), pt)
+}
+
+// RegisterErrorType registers the problem type for the type of error, it's matched by errors.As.
+// The target should be an error value of the type, e.g. (*os.PathError)(nil)
+func (r *ProblemRegistry) RegisterErrorType(target error, pt ProblemType) *ProblemRegistry {
+	return r.addMatcher(elton.NewErrorTypeMatcher(target), pt)
+}
+
+// RegisterCategory registers the problem type for the category of hes.Error
+func (r *ProblemRegistry) RegisterCategory(category string, pt ProblemType) *ProblemRegistry {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.categories[category] = &pt
+	return r
+}
+
+// Lookup returns the problem type of error, the error is matched first,
+// and then the category of hes.Error.
+func (r *ProblemRegistry) Lookup(err error) *ProblemType {
+	if err == nil {
+		return nil
+	}
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	for _, matcher := range r.matchers {
+		if matcher.match(err) {
+			return matcher.problemType
+		}
+	}
+	he, ok := err.(*hes.Error)
+	if ok && he.Category != "" {
+		return r.categories[he.Category]
+	}
+	return nil
+}
+
+// MarshalJSON marshals the problem with the extension members,
+// the standard members can't be overridden by extension.
+func (p Problem) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{}, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		m[k] = v
+	}
+	m["type"] = p.Type
+	m["title"] = p.Title
+	m["status"] = p.Status
+	if p.Detail != "" {
+		m["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		m["instance"] = p.Instance
+	}
+	return json.Marshal(m)
+}
+
+// NewProblem returns a new problem details of error, the instance is the id of context
+// and the extension members are the extra of hes.Error.
+func NewProblem(c *elton.Context, he *hes.Error, pt *ProblemType) *Problem {
+	p := &Problem{
+		Type:     DefaultProblemType,
+		Status:   he.StatusCode,
+		Title:    he.Title,
+		Detail:   he.Message,
+		Instance: c.ID,
+	}
+	if pt != nil {
+		if pt.Type != "" {
+			p.Type = pt.Type
+		}
+		if pt.Title != "" {
+			p.Title = pt.Title
+		}
+		if pt.StatusCode != 0 {
+			p.Status = pt.StatusCode
+		}
+	}
+	if p.Status == 0 {
+		p.Status = http.StatusInternalServerError
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	if len(he.Extra) != 0 {
+		p.Extensions = make(map[string]interface{}, len(he.Extra))
+		for k, v := range he.Extra {
+			p.Extensions[k] = v
+		}
+	}
+	return p
+}
@@ -0,0 +1,108 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vicanso/elton"
+	"github.com/vicanso/hes"
+)
+
+func TestProblemRegistry(t *testing.T) {
+	assert := assert.New(t)
+
+	notFound := ProblemType{
+		Type:       "https://example.com/problems/not-found",
+		StatusCode: 404,
+	}
+	pathErr := ProblemType{
+		Type: "https://example.com/problems/path",
+	}
+	validate := ProblemType{
+		Type:  "https://example.com/problems/validate",
+		Title: "Validation Failed",
+	}
+	r := NewProblemRegistry().
+		RegisterError(fs.ErrNotExist, notFound).
+		RegisterErrorType((*os.PathError)(nil), pathErr).
+		RegisterCategory("validate", validate)
+
+	assert.Nil(r.Lookup(nil))
+	assert.Nil(r.Lookup(errors.New("abc")))
+	assert.Equal(&notFound, r.Lookup(fmt.Errorf("get user fail: %w", fs.ErrNotExist)))
+	// 按注册顺序匹配
+	_, err := os.Open("/not-exists-file")
+	assert.Equal(&notFound, r.Lookup(err))
+	assert.Equal(&pathErr, r.Lookup(&os.PathError{
+		Op:  "open",
+		Err: errors.New("abc"),
+	}))
+	// hes.Error包装的error
+	assert.Equal(&notFound, r.Lookup(hes.Wrap(fs.ErrNotExist)))
+	assert.Equal(&validate, r.Lookup(hes.New("invalid name", "validate")))
+	assert.Nil(r.Lookup(hes.New("abc", "unknown")))
+}
+
+func TestNewProblem(t *testing.T) {
+	assert := assert.New(t)
+
+	c := elton.NewContext(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	c.ID = "abcd"
+	he := hes.New("name is invalid")
+	he.Extra = map[string]interface{}{
+		"field":  "name",
+		"status": 1,
+	}
+	p := NewProblem(c, he, nil)
+	assert.Equal(&Problem{
+		Type:     DefaultProblemType,
+		Title:    "Bad Request",
+		Status:   400,
+		Detail:   "name is invalid",
+		Instance: "abcd",
+		Extensions: map[string]interface{}{
+			"field":  "name",
+			"status": 1,
+		},
+	}, p)
+	buf, err := json.Marshal(p)
+	assert.Nil(err)
+	// 扩展字段不可覆盖标准字段
+	assert.Equal(`{"detail":"name is invalid","field":"name","instance":"abcd","status":400,"title":"Bad Request","type":"about:blank"}`, string(buf))
+
+	p = NewProblem(c, he, &ProblemType{
+		Type:       "https://example.com/problems/validate",
+		Title:      "Validation Failed",
+		StatusCode: 422,
+	})
+	assert.Equal("https://example.com/problems/validate", p.Type)
+	assert.Equal("Validation Failed", p.Title)
+	assert.Equal(422, p.Status)
+}
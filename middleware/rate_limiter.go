@@ -0,0 +1,314 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/vicanso/elton"
+	"github.com/vicanso/hes"
+)
+
+const (
+	// ErrRateLimiterCategory rate limiter error category
+	ErrRateLimiterCategory = "elton-rate-limiter"
+	// RateLimitTokenBucket token bucket algorithm
+	RateLimitTokenBucket = "token-bucket"
+	// RateLimitSlidingWindow sliding window algorithm
+	RateLimitSlidingWindow = "sliding-window"
+
+	// HeaderRateLimitLimit RateLimit-Limit
+	HeaderRateLimitLimit = "RateLimit-Limit"
+	// HeaderRateLimitRemaining RateLimit-Remaining
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	// HeaderRateLimitReset RateLimit-Reset
+	HeaderRateLimitReset = "RateLimit-Reset"
+	// HeaderRetryAfter Retry-After
+	HeaderRetryAfter = "Retry-After"
+)
+
+type (
+	// RateLimit rate limit, allow Limit requests per Period
+	RateLimit struct {
+		// Algorithm token bucket or sliding window
+		Algorithm string
+		Limit     int
+		Period    time.Duration
+	}
+	// RateLimitResult result of rate limit
+	RateLimitResult struct {
+		// Allowed the request is allowed
+		Allowed bool
+		// Limit the max requests of period
+		Limit int
+		// Remaining the remaining requests
+		Remaining int
+		// Reset the duration to reset the limit
+		Reset time.Duration
+		// RetryAfter the duration to retry if it's not allowed
+		RetryAfter time.Duration
+	}
+	// RateLimiterStore rate limiter store
+	RateLimiterStore interface {
+		// Take takes one request of the key
+		Take(key string, rate RateLimit) (*RateLimitResult, error)
+	}
+	// RateLimiterConfig rate limiter config
+	RateLimiterConfig struct {
+		// Keys keys for generate limit key, it's the same as concurrent limiter
+		Keys []string
+		// Algorithm token bucket or sliding window, default is token bucket
+		Algorithm string
+		// Limit the max requests of period
+		Limit int
+		// Period the period of limit, default is one second
+		Period time.Duration
+		// Store rate limiter store, default is local store
+		Store RateLimiterStore
+		// NotAllowEmpty if value is empty, will return error
+		NotAllowEmpty bool
+		// DisableHeader disable the RateLimit-* header of response
+		DisableHeader bool
+		Skipper       elton.Skipper
+	}
+	rateLimitBucket struct {
+		// token bucket
+		tokens float64
+		// sliding window
+		windowStart   time.Time
+		count         int
+		previousCount int
+		updatedAt     time.Time
+	}
+	// LocalRateLimiterStore local rate limiter store
+	LocalRateLimiterStore struct {
+		mutex     sync.Mutex
+		m         map[string]*rateLimitBucket
+		nextPrune time.Time
+		now       func() time.Time
+	}
+)
+
+var (
+	// ErrRateLimitExceeded rate limit exceeded
+	ErrRateLimitExceeded = &hes.Error{
+		StatusCode: http.StatusTooManyRequests,
+		Message:    "rate limit exceeded",
+		Category:   ErrRateLimiterCategory,
+	}
+	// ErrRateLimiterInvalidLimit invalid limit
+	ErrRateLimiterInvalidLimit = errors.New("limit should be > 0")
+	// ErrRateLimiterInvalidAlgorithm invalid algorithm
+	ErrRateLimiterInvalidAlgorithm = errors.New("algorithm should be token-bucket or sliding-window")
+)
+
+// NewLocalRateLimiterStore returns a new local rate limiter store,
+// it's useful for limit rate for process.
+// The store should not be shared by limiters with different periods.
+func NewLocalRateLimiterStore() *LocalRateLimiterStore {
+	return &LocalRateLimiterStore{
+		m:   make(map[string]*rateLimitBucket),
+		now: time.Now,
+	}
+}
+
+// prune removes the idle buckets
+func (s *LocalRateLimiterStore) prune(now time.Time, period time.Duration) {
+	if now.Before(s.nextPrune) {
+		return
+	}
+	for key, bucket := range s.m {
+		// 两个周期内无更新的可直接删除（token已满或窗口已过期）
+		if now.Sub(bucket.updatedAt) > 2*period {
+			delete(s.m, key)
+		}
+	}
+	s.nextPrune = now.Add(period)
+}
+
+// Take takes one request of the key
+func (s *LocalRateLimiterStore) Take(key string, rate RateLimit) (*RateLimitResult, error) {
+	if rate.Limit <= 0 || rate.Period <= 0 {
+		return nil, ErrRateLimiterInvalidLimit
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := s.now()
+	s.prune(now, rate.Period)
+	bucket, ok := s.m[key]
+	if !ok {
+		bucket = &rateLimitBucket{
+			tokens:      float64(rate.Limit),
+			windowStart: now,
+			updatedAt:   now,
+		}
+		s.m[key] = bucket
+	}
+	switch rate.Algorithm {
+	case RateLimitSlidingWindow:
+		return bucket.takeSlidingWindow(now, rate), nil
+	case "", RateLimitTokenBucket:
+		return bucket.takeTokenBucket(now, rate), nil
+	default:
+		return nil, ErrRateLimiterInvalidAlgorithm
+	}
+}
+
+func (b *rateLimitBucket) takeTokenBucket(now time.Time, rate RateLimit) *RateLimitResult {
+	limit := float64(rate.Limit)
+	// 每纳秒生成的token
+	perNano := limit / float64(rate.Period)
+	elapsed := now.Sub(b.updatedAt)
+	b.tokens = math.Min(limit, b.tokens+float64(elapsed)*perNano)
+	b.updatedAt = now
+
+	result := &RateLimitResult{
+		Limit: rate.Limit,
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration(math.Ceil((1 - b.tokens) / perNano))
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = time.Duration(math.Ceil((limit - b.tokens) / perNano))
+	return result
+}
+
+func (b *rateLimitBucket) takeSlidingWindow(now time.Time, rate RateLimit) *RateLimitResult {
+	period := rate.Period
+	elapsed := now.Sub(b.windowStart)
+	if elapsed >= period {
+		windows := elapsed / period
+		// 如果只过了一个窗口，则当前窗口数量为上一窗口数量
+		if windows == 1 {
+			b.previousCount = b.count
+		} else {
+			b.previousCount = 0
+		}
+		b.count = 0
+		b.windowStart = b.windowStart.Add(windows * period)
+		elapsed = now.Sub(b.windowStart)
+	}
+	b.updatedAt = now
+	// 按上一窗口剩余的时间占比估算上一窗口的请求数
+	weight := float64(period-elapsed) / float64(period)
+	estimated := float64(b.previousCount)*weight + float64(b.count)
+
+	result := &RateLimitResult{
+		Limit: rate.Limit,
+		Reset: period - elapsed,
+	}
+	if estimated+1 <= float64(rate.Limit) {
+		b.count++
+		estimated++
+		result.Allowed = true
+	} else {
+		retryAfter := period - elapsed
+		// 上一窗口的请求数随时间减少，计算可再次请求的时间
+		if b.previousCount != 0 && b.count < rate.Limit {
+			over := estimated + 1 - float64(rate.Limit)
+			d := time.Duration(math.Ceil(over / float64(b.previousCount) * float64(period)))
+			if d < retryAfter {
+				retryAfter = d
+			}
+		}
+		result.RetryAfter = retryAfter
+	}
+	result.Remaining = int(float64(rate.Limit) - estimated)
+	if result.Remaining < 0 {
+		result.Remaining = 0
+	}
+	return result
+}
+
+func toCeilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// NewRateLimiter returns a new rate limiter middleware,
+// the keys of limiter are the same as concurrent limiter(:ip, h:, q:, p: and field of body).
+// It will set the RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset header,
+// and Retry-After header if the request is limited.
+// It will throw a panic if the limit is <= 0 or the algorithm is invalid.
+func NewRateLimiter(config RateLimiterConfig) elton.Handler {
+	if config.Limit <= 0 {
+		panic(ErrRateLimiterInvalidLimit)
+	}
+	algorithm := config.Algorithm
+	if algorithm == "" {
+		algorithm = RateLimitTokenBucket
+	}
+	if algorithm != RateLimitTokenBucket && algorithm != RateLimitSlidingWindow {
+		panic(ErrRateLimiterInvalidAlgorithm)
+	}
+	period := config.Period
+	if period <= 0 {
+		period = time.Second
+	}
+	rate := RateLimit{
+		Algorithm: algorithm,
+		Limit:     config.Limit,
+		Period:    period,
+	}
+	store := config.Store
+	if store == nil {
+		store = NewLocalRateLimiterStore()
+	}
+	keys := parseLimiterKeys(config.Keys)
+	skipper := config.Skipper
+	if skipper == nil {
+		skipper = elton.DefaultSkipper
+	}
+	return func(c *elton.Context) (err error) {
+		if skipper(c) {
+			return c.Next()
+		}
+		key, err := getLimiterKey(c, keys, config.NotAllowEmpty)
+		if err != nil {
+			return
+		}
+		result, err := store.Take(key, rate)
+		if err != nil {
+			err = hes.Wrap(err)
+			return
+		}
+		if !config.DisableHeader {
+			c.SetHeader(HeaderRateLimitLimit, strconv.Itoa(result.Limit))
+			c.SetHeader(HeaderRateLimitRemaining, strconv.Itoa(result.Remaining))
+			c.SetHeader(HeaderRateLimitReset, toCeilSeconds(result.Reset))
+		}
+		if !result.Allowed {
+			c.SetHeader(HeaderRetryAfter, toCeilSeconds(result.RetryAfter))
+			err = ErrRateLimitExceeded
+			return
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,204 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vicanso/elton"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (fc *fakeClock) Now() time.Time {
+	return fc.now
+}
+
+func (fc *fakeClock) Add(d time.Duration) {
+	fc.now = fc.now.Add(d)
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now: time.Unix(1609459200, 0),
+	}
+}
+
+func TestLocalRateLimiterStoreTokenBucket(t *testing.T) {
+	assert := assert.New(t)
+	clock := newFakeClock()
+	store := NewLocalRateLimiterStore()
+	store.now = clock.Now
+	rate := RateLimit{
+		Algorithm: RateLimitTokenBucket,
+		Limit:     2,
+		Period:    time.Second,
+	}
+
+	result, err := store.Take("a", rate)
+	assert.Nil(err)
+	assert.True(result.Allowed)
+	assert.Equal(1, result.Remaining)
+	assert.Equal(500*time.Millisecond, result.Reset)
+
+	result, _ = store.Take("a", rate)
+	assert.True(result.Allowed)
+	assert.Equal(0, result.Remaining)
+
+	result, _ = store.Take("a", rate)
+	assert.False(result.Allowed)
+	assert.Equal(500*time.Millisecond, result.RetryAfter)
+
+	// 其它的key不受影响
+	result, _ = store.Take("b", rate)
+	assert.True(result.Allowed)
+
+	// 生成了新的token
+	clock.Add(500 * time.Millisecond)
+	result, _ = store.Take("a", rate)
+	assert.True(result.Allowed)
+	assert.Equal(0, result.Remaining)
+
+	_, err = store.Take("a", RateLimit{})
+	assert.Equal(ErrRateLimiterInvalidLimit, err)
+	_, err = store.Take("a", RateLimit{
+		Algorithm: "a",
+		Limit:     1,
+		Period:    time.Second,
+	})
+	assert.Equal(ErrRateLimiterInvalidAlgorithm, err)
+}
+
+func TestLocalRateLimiterStoreSlidingWindow(t *testing.T) {
+	assert := assert.New(t)
+	clock := newFakeClock()
+	store := NewLocalRateLimiterStore()
+	store.now = clock.Now
+	rate := RateLimit{
+		Algorithm: RateLimitSlidingWindow,
+		Limit:     2,
+		Period:    time.Second,
+	}
+	result, err := store.Take("a", rate)
+	assert.Nil(err)
+	assert.True(result.Allowed)
+	assert.Equal(1, result.Remaining)
+	assert.Equal(time.Second, result.Reset)
+
+	result, _ = store.Take("a", rate)
+	assert.True(result.Allowed)
+	assert.Equal(0, result.Remaining)
+
+	result, _ = store.Take("a", rate)
+	assert.False(result.Allowed)
+	assert.Equal(time.Second, result.RetryAfter)
+
+	// 下一窗口的开始，上一窗口的请求仍全部计算
+	clock.Add(time.Second)
+	result, _ = store.Take("a", rate)
+	assert.False(result.Allowed)
+	assert.Equal(500*time.Millisecond, result.RetryAfter)
+
+	// 上一窗口的权重为一半
+	clock.Add(500 * time.Millisecond)
+	result, _ = store.Take("a", rate)
+	assert.True(result.Allowed)
+	assert.Equal(0, result.Remaining)
+
+	// 已过去多个窗口
+	clock.Add(3 * time.Second)
+	result, _ = store.Take("a", rate)
+	assert.True(result.Allowed)
+	assert.Equal(1, result.Remaining)
+}
+
+func TestRateLimiterPanic(t *testing.T) {
+	assert := assert.New(t)
+	func() {
+		defer func() {
+			r := recover()
+			assert.Equal(ErrRateLimiterInvalidLimit, r.(error))
+		}()
+		NewRateLimiter(RateLimiterConfig{})
+	}()
+	func() {
+		defer func() {
+			r := recover()
+			assert.Equal(ErrRateLimiterInvalidAlgorithm, r.(error))
+		}()
+		NewRateLimiter(RateLimiterConfig{
+			Limit:     1,
+			Algorithm: "a",
+		})
+	}()
+}
+
+func TestRateLimiter(t *testing.T) {
+	assert := assert.New(t)
+	skipErr := errors.New("skip error")
+	fn := NewRateLimiter(RateLimiterConfig{
+		Keys: []string{
+			":ip",
+			"q:type",
+		},
+		Limit:  1,
+		Period: time.Minute,
+	})
+	newContext := func() *elton.Context {
+		req := httptest.NewRequest("GET", "/users?type=1", nil)
+		c := elton.NewContext(httptest.NewRecorder(), req)
+		c.Next = func() error {
+			return skipErr
+		}
+		return c
+	}
+
+	c := newContext()
+	err := fn(c)
+	assert.Equal(skipErr, err)
+	assert.Equal("1", c.GetHeader(HeaderRateLimitLimit))
+	assert.Equal("0", c.GetHeader(HeaderRateLimitRemaining))
+	assert.Equal("60", c.GetHeader(HeaderRateLimitReset))
+	assert.Empty(c.GetHeader(HeaderRetryAfter))
+
+	c = newContext()
+	err = fn(c)
+	assert.Equal(ErrRateLimitExceeded, err)
+	assert.Equal("0", c.GetHeader(HeaderRateLimitRemaining))
+	assert.Equal("60", c.GetHeader(HeaderRetryAfter))
+
+	fn = NewRateLimiter(RateLimiterConfig{
+		Keys: []string{
+			"p:id",
+		},
+		Limit:         1,
+		NotAllowEmpty: true,
+	})
+	assert.Equal(ErrNotAllowEmpty, fn(newContext()))
+}
@@ -26,6 +26,8 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/vicanso/elton"
 	"github.com/vicanso/hes"
@@ -36,48 +38,191 @@ const (
 	ErrRecoverCategory = "elton-recover"
 )
 
+var (
+	// ErrRecover the sanitized error of panic for response
+	ErrRecover = &hes.Error{
+		StatusCode: http.StatusInternalServerError,
+		Message:    "internal server error",
+		Category:   ErrRecoverCategory,
+	}
+)
+
+type (
+	// RecoverInfo the info of panic
+	RecoverInfo struct {
+		Method string `json:"method,omitempty"`
+		Route  string `json:"route,omitempty"`
+		URI    string `json:"uri,omitempty"`
+		ID     string `json:"id,omitempty"`
+		IP     string `json:"ip,omitempty"`
+		// Value the recovered value of panic
+		Value interface{} `json:"-"`
+		// Message the message of panic value
+		Message string   `json:"message,omitempty"`
+		Stack   []string `json:"stack,omitempty"`
+		// Suppressed the count of panics which are not reported since the last report
+		Suppressed int `json:"suppressed,omitempty"`
+	}
+	// RecoverConfig recover config
+	RecoverConfig struct {
+		// OnPanic the reporting hook of panic, the error is the full error with stack
+		OnPanic func(info *RecoverInfo, err error)
+		// ReportInterval limits the reports of each route,
+		// only one panic is reported in the interval
+		ReportInterval time.Duration
+	}
+	recoverReport struct {
+		reportedAt time.Time
+		suppressed int
+	}
+	recoverReporter struct {
+		config  RecoverConfig
+		mutex   sync.Mutex
+		reports map[string]*recoverReport
+	}
+)
+
+func newRecoverReporter(config RecoverConfig) *recoverReporter {
+	return &recoverReporter{
+		config:  config,
+		reports: make(map[string]*recoverReport),
+	}
+}
+
+// allow returns whether the panic of the route should be reported,
+// and the count of panics which are suppressed since the last report
+func (rr *recoverReporter) allow(key string) (bool, int) {
+	interval := rr.config.ReportInterval
+	if interval <= 0 {
+		return true, 0
+	}
+	rr.mutex.Lock()
+	defer rr.mutex.Unlock()
+	now := time.Now()
+	report := rr.reports[key]
+	if report == nil {
+		report = &recoverReport{}
+		rr.reports[key] = report
+	} else if now.Sub(report.reportedAt) < interval {
+		report.suppressed++
+		return false, 0
+	}
+	suppressed := report.suppressed
+	report.suppressed = 0
+	report.reportedAt = now
+	return true, suppressed
+}
+
+func (rr *recoverReporter) report(info *RecoverInfo, err error) {
+	if rr.config.OnPanic == nil {
+		return
+	}
+	allowed, suppressed := rr.allow(info.Method + " " + info.Route)
+	if !allowed {
+		return
+	}
+	info.Suppressed = suppressed
+	rr.config.OnPanic(info, err)
+}
+
+// newRecoverError converts the recovered value to hes.Error with stack
+func newRecoverError(err error) *hes.Error {
+	he := hes.Wrap(err)
+	he.Category = ErrRecoverCategory
+	he.StatusCode = http.StatusInternalServerError
+	he.Exception = true
+	he.Extra = map[string]interface{}{
+		elton.ErrorStackKey: elton.ErrorStack(err),
+	}
+	return he
+}
+
+// writeRecoverResponse writes the sanitized error to response
+func writeRecoverResponse(resp http.ResponseWriter, req *http.Request) error {
+	header := resp.Header()
+	// 出错时清除部分响应头
+	for _, key := range []string{
+		elton.HeaderETag,
+		elton.HeaderLastModified,
+		elton.HeaderContentEncoding,
+		elton.HeaderContentLength,
+	} {
+		header.Del(key)
+	}
+	buf := []byte(ErrRecover.Error())
+	if strings.Contains(req.Header.Get("Accept"), "application/json") {
+		header.Set(elton.HeaderContentType, elton.MIMEApplicationJSON)
+		buf = ErrRecover.ToJSON()
+	} else {
+		header.Set(elton.HeaderContentType, elton.MIMETextPlain)
+	}
+	resp.WriteHeader(ErrRecover.StatusCode)
+	_, err := resp.Write(buf)
+	return err
+}
+
 // NewRecover return a recover middleware, it can recover from panic,
 // and then emit an `elton-recover` error.
 // Suggest to graceful close the elton instance for recover error.
 func NewRecover() elton.Handler {
+	return NewRecoverWithConfig(RecoverConfig{})
+}
+
+// NewRecoverWithConfig return a recover middleware with config,
+// the panic will be converted to an `elton-recover` error with stack and emitted,
+// and the reporting hook will be called with the summary of request.
+// The response is a sanitized error(ErrRecover).
+func NewRecoverWithConfig(config RecoverConfig) elton.Handler {
+	reporter := newRecoverReporter(config)
 	return func(c *elton.Context) error {
 		defer func() {
-			// 可针对实际需求调整，如对于每个recover增加邮件通知等
-			if r := recover(); r != nil {
-				err, ok := r.(error)
-				if !ok {
-					err = fmt.Errorf("%v", r)
-				}
-
-				he := hes.Wrap(err)
-				he.Category = ErrRecoverCategory
-				he.StatusCode = http.StatusInternalServerError
-				err = he
+			r := recover()
+			if r == nil {
+				return
+			}
+			// 与net/http一致，中止处理的panic不处理
+			if r == http.ErrAbortHandler {
+				panic(r)
+			}
+			he := newRecoverError(elton.RecoverError(r))
+			if c.Elton() != nil {
+				c.Elton().EmitError(c, he)
+			}
+			reporter.report(&RecoverInfo{
+				Method:  c.Request.Method,
+				Route:   c.Route,
+				URI:     c.Request.RequestURI,
+				ID:      c.ID,
+				IP:      c.ClientIP(),
+				Value:   r,
+				Message: fmt.Sprintf("%v", r),
+				Stack:   elton.ErrorStack(he),
+			}, he)
+			// 直接对Response写入数据，则将 Committed设置为 true
+			c.Committed = true
+			err := writeRecoverResponse(c.Response, c.Request)
+			if err != nil && c.Elton() != nil {
 				c.Elton().EmitError(c, err)
-				// 出错时清除部分响应头
-				for _, key := range []string{
-					elton.HeaderETag,
-					elton.HeaderLastModified,
-					elton.HeaderContentEncoding,
-					elton.HeaderContentLength,
-				} {
-					c.SetHeader(key, "")
-				}
-				// 直接对Response写入数据，则将 Committed设置为 true
-				c.Committed = true
-				resp := c.Response
-				buf := []byte(err.Error())
-				if strings.Contains(c.GetRequestHeader("Accept"), "application/json") {
-					c.SetHeader(elton.HeaderContentType, elton.MIMEApplicationJSON)
-					buf = he.ToJSON()
-				}
-				resp.WriteHeader(he.StatusCode)
-				_, err = resp.Write(buf)
-				if err != nil {
-					c.Elton().EmitError(c, err)
-				}
 			}
 		}()
 		return c.Next()
 	}
 }
+
+// NewPanicHandler returns a panic handler of elton, it handles the panic
+// in pre handlers and ServeHTTP, which is not recovered by recover middleware.
+func NewPanicHandler(config RecoverConfig) elton.PanicHandler {
+	reporter := newRecoverReporter(config)
+	return func(resp http.ResponseWriter, req *http.Request, err error) {
+		he := newRecoverError(err)
+		reporter.report(&RecoverInfo{
+			Method:  req.Method,
+			URI:     req.RequestURI,
+			IP:      elton.GetClientIP(req),
+			Value:   err,
+			Message: err.Error(),
+			Stack:   elton.ErrorStack(he),
+		}, he)
+		_ = writeRecoverResponse(resp, req)
+	}
+}
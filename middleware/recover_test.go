@@ -23,12 +23,16 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/vicanso/elton"
+	"github.com/vicanso/hes"
 )
 
 func TestRecoverResponseText(t *testing.T) {
@@ -60,7 +64,8 @@ func TestRecoverResponseText(t *testing.T) {
 
 	e.ServeHTTP(resp, req)
 	assert.Equal(http.StatusInternalServerError, resp.Code)
-	assert.Equal("statusCode=500, category=elton-recover, message=abc", resp.Body.String())
+	// 响应的出错信息不包括panic的数据
+	assert.Equal("statusCode=500, category=elton-recover, message=internal server error", resp.Body.String())
 	assert.True(ctx.Committed)
 	assert.True(catchError)
 	for _, key := range keys {
@@ -83,3 +88,97 @@ func TestRecoverResponseJSON(t *testing.T) {
 	assert.Equal(elton.MIMEApplicationJSON, resp.Header().Get(elton.HeaderContentType))
 	assert.NotEmpty(resp.Body.Bytes())
 }
+
+func TestRecoverWithConfig(t *testing.T) {
+	assert := assert.New(t)
+	infos := make([]*RecoverInfo, 0)
+	var emitErr error
+	e := elton.New()
+	e.GenerateID = func() string {
+		return "abcd"
+	}
+	e.OnError(func(_ *elton.Context, err error) {
+		emitErr = err
+	})
+	e.Use(NewRecoverWithConfig(RecoverConfig{
+		OnPanic: func(info *RecoverInfo, err error) {
+			infos = append(infos, info)
+		},
+		ReportInterval: time.Hour,
+	}))
+	e.GET("/users/{id}", func(c *elton.Context) error {
+		panic(errors.New("connect to db fail"))
+	})
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("GET", "/users/1?type=vip", nil)
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set(elton.HeaderXForwardedFor, "1.1.1.1")
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, req)
+		assert.Equal(500, resp.Code)
+		assert.Equal(`{"statusCode":500,"category":"elton-recover","message":"internal server error"}`, resp.Body.String())
+	}
+
+	// 一小时内仅报告一次
+	assert.Equal(1, len(infos))
+	info := infos[0]
+	assert.Equal("GET", info.Method)
+	assert.Equal("/users/{id}", info.Route)
+	assert.Equal("/users/1?type=vip", info.URI)
+	assert.Equal("abcd", info.ID)
+	assert.Equal("1.1.1.1", info.IP)
+	assert.Equal("connect to db fail", info.Message)
+	assert.True(strings.HasPrefix(info.Stack[0], "github.com/vicanso/elton/middleware.TestRecoverWithConfig."))
+
+	he, ok := emitErr.(*hes.Error)
+	assert.True(ok)
+	assert.Equal("connect to db fail", he.Message)
+	assert.True(he.Exception)
+	assert.Equal(info.Stack, elton.ErrorStack(he))
+}
+
+func TestRecoverReporter(t *testing.T) {
+	assert := assert.New(t)
+	rr := newRecoverReporter(RecoverConfig{
+		ReportInterval: 10 * time.Millisecond,
+	})
+	allowed, suppressed := rr.allow("GET /")
+	assert.True(allowed)
+	assert.Equal(0, suppressed)
+	allowed, _ = rr.allow("GET /")
+	assert.False(allowed)
+	allowed, _ = rr.allow("GET /")
+	assert.False(allowed)
+	// 不同的路由分开限制
+	allowed, _ = rr.allow("GET /users")
+	assert.True(allowed)
+
+	time.Sleep(15 * time.Millisecond)
+	allowed, suppressed = rr.allow("GET /")
+	assert.True(allowed)
+	assert.Equal(2, suppressed)
+}
+
+func TestPanicHandler(t *testing.T) {
+	assert := assert.New(t)
+	var info *RecoverInfo
+	e := elton.New()
+	e.PanicHandler = NewPanicHandler(RecoverConfig{
+		OnPanic: func(i *RecoverInfo, _ error) {
+			info = i
+		},
+	})
+	e.Pre(func(req *http.Request) {
+		panic("pre panic")
+	})
+	e.GET("/", func(c *elton.Context) error {
+		return nil
+	})
+	req := httptest.NewRequest("GET", "/", nil)
+	resp := httptest.NewRecorder()
+	e.ServeHTTP(resp, req)
+	assert.Equal(500, resp.Code)
+	assert.Equal("statusCode=500, category=elton-recover, message=internal server error", resp.Body.String())
+	assert.Equal("pre panic", info.Message)
+	assert.True(strings.HasPrefix(info.Stack[0], "github.com/vicanso/elton/middleware.TestPanicHandler."))
+}
@@ -26,6 +26,8 @@ import (
 	"bytes"
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/vicanso/elton"
 	"github.com/vicanso/hes"
@@ -39,7 +41,16 @@ type (
 		Marshal func(v interface{}) ([]byte, error)
 		// ContentType response's content type
 		ContentType string
+		// Envelope wraps the response as {"data": ..., "meta": ...},
+		// the meta is the pagination of context. The error is still returned,
+		// use error handler with ErrorResponseTypeEnvelope to wrap it as {"error": ...}.
+		Envelope bool
+		// FieldsQuery the query name of sparse fieldsets, e.g. ?fields=a,b.c,
+		// field selection is disabled if it's empty.
+		FieldsQuery string
 	}
+	// responderFields the selected fields, nil means all fields are selected
+	responderFields map[string]responderFields
 )
 
 const (
@@ -123,8 +134,30 @@ func NewResponder(config ResponderConfig) elton.Handler {
 				}
 				body = data
 			default:
+				var fields responderFields
+				if config.FieldsQuery != "" {
+					fields = parseResponderFields(c.QueryParam(config.FieldsQuery))
+				}
+				var value interface{} = data
+				var e error
+				if len(fields) != 0 {
+					value, e = selectResponderFields(data, fields)
+				}
+				pagination := c.GetPagination()
+				if e == nil && config.Envelope {
+					envelope := map[string]interface{}{
+						"data": value,
+					}
+					if pagination != nil {
+						envelope["meta"] = pagination.Meta()
+					}
+					value = envelope
+				}
+				var buf []byte
 				// 使用marshal转换（默认为转换为json）
-				buf, e := marshal(data)
+				if e == nil {
+					buf, e = marshal(value)
+				}
 				if e != nil {
 					he := hes.NewWithErrorStatusCode(e, http.StatusInternalServerError)
 					he.Category = ErrResponderCategory
@@ -135,6 +168,9 @@ func NewResponder(config ResponderConfig) elton.Handler {
 				if !hadContentType {
 					c.SetHeader(elton.HeaderContentType, contentType)
 				}
+				if pagination != nil {
+					setPaginationHeader(c, pagination)
+				}
 				body = buf
 			}
 		}
@@ -150,3 +186,87 @@ func NewResponder(config ResponderConfig) elton.Handler {
 		return nil
 	}
 }
+
+// setPaginationHeader sets the link and total count header of pagination
+func setPaginationHeader(c *elton.Context, p *elton.Pagination) {
+	if c.Request != nil && c.Request.URL != nil {
+		c.SetHeader(elton.HeaderLink, p.Link(c.Request.URL))
+	}
+	if p.Total >= 0 {
+		c.SetHeader(elton.HeaderXTotalCount, strconv.FormatInt(p.Total, 10))
+	}
+}
+
+// parseResponderFields parses the fields, e.g. a,b.c
+func parseResponderFields(value string) responderFields {
+	fields := make(responderFields)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		current := fields
+		keys := strings.Split(item, ".")
+		for index, key := range keys {
+			sub, exists := current[key]
+			// 已选择了所有字段
+			if exists && sub == nil {
+				break
+			}
+			if index == len(keys)-1 {
+				current[key] = nil
+				break
+			}
+			if !exists {
+				sub = make(responderFields)
+				current[key] = sub
+			}
+			current = sub
+		}
+	}
+	return fields
+}
+
+// selectResponderFields converts the data to json value and selects the fields of it
+func selectResponderFields(data interface{}, fields responderFields) (interface{}, error) {
+	buf, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(buf))
+	// 避免数字转换为float64丢失精度
+	decoder.UseNumber()
+	err = decoder.Decode(&value)
+	if err != nil {
+		return nil, err
+	}
+	return filterResponderFields(value, fields), nil
+}
+
+// filterResponderFields filters the fields of map, each item of array will be filtered
+func filterResponderFields(value interface{}, fields responderFields) interface{} {
+	if fields == nil {
+		return value
+	}
+	switch data := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(fields))
+		for key, sub := range fields {
+			v, ok := data[key]
+			if !ok {
+				continue
+			}
+			result[key] = filterResponderFields(v, sub)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(data))
+		for index, item := range data {
+			result[index] = filterResponderFields(item, fields)
+		}
+		return result
+	default:
+		return value
+	}
+}
@@ -209,3 +209,131 @@ func TestResponder(t *testing.T) {
 		assert.Equal(tt.contentType, c.GetHeader(elton.HeaderContentType))
 	}
 }
+
+func TestParseResponderFields(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(responderFields{}, parseResponderFields(""))
+	assert.Equal(responderFields{
+		"a": nil,
+		"b": responderFields{
+			"c": nil,
+			"d": nil,
+		},
+	}, parseResponderFields("a, b.c,b.d,"))
+	// 已选择所有字段，子字段忽略
+	assert.Equal(responderFields{
+		"a": nil,
+	}, parseResponderFields("a,a.b"))
+}
+
+func TestResponderFields(t *testing.T) {
+	assert := assert.New(t)
+	type Address struct {
+		City   string `json:"city"`
+		Street string `json:"street"`
+	}
+	type User struct {
+		ID      int64     `json:"id"`
+		Name    string    `json:"name"`
+		Address []Address `json:"address"`
+	}
+	fn := NewResponder(ResponderConfig{
+		FieldsQuery: "fields",
+	})
+
+	req := httptest.NewRequest("GET", "/users?fields=id,address.city", nil)
+	c := elton.NewContext(httptest.NewRecorder(), req)
+	c.Next = func() error {
+		c.Body = []User{
+			{
+				ID:   9007199254740993,
+				Name: "tree.xie",
+				Address: []Address{
+					{
+						City:   "GZ",
+						Street: "abc",
+					},
+				},
+			},
+		}
+		return nil
+	}
+	err := fn(c)
+	assert.Nil(err)
+	assert.Equal(`[{"address":[{"city":"GZ"}],"id":9007199254740993}]`, c.BodyBuffer.String())
+
+	// 未指定字段
+	req = httptest.NewRequest("GET", "/users", nil)
+	c = elton.NewContext(httptest.NewRecorder(), req)
+	c.Next = func() error {
+		c.Body = &User{
+			ID: 1,
+		}
+		return nil
+	}
+	err = fn(c)
+	assert.Nil(err)
+	assert.Equal(`{"id":1,"name":"","address":null}`, c.BodyBuffer.String())
+}
+
+func TestResponderEnvelope(t *testing.T) {
+	assert := assert.New(t)
+	fn := NewResponder(ResponderConfig{
+		Envelope:    true,
+		FieldsQuery: "fields",
+	})
+
+	req := httptest.NewRequest("GET", "/users?page=2&limit=2&fields=name", nil)
+	c := elton.NewContext(httptest.NewRecorder(), req)
+	c.Next = func() error {
+		p, err := c.ParsePagination(10, 100)
+		if err != nil {
+			return err
+		}
+		p.Total = 5
+		c.Body = []map[string]string{
+			{
+				"id":   "3",
+				"name": "a",
+			},
+			{
+				"id":   "4",
+				"name": "b",
+			},
+		}
+		return nil
+	}
+	err := fn(c)
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, c.StatusCode)
+	assert.Equal(`{"data":[{"name":"a"},{"name":"b"}],"meta":{"limit":2,"page":2,"total":5,"totalPages":3}}`, c.BodyBuffer.String())
+	assert.Equal("5", c.GetHeader(elton.HeaderXTotalCount))
+	assert.Equal(`</users?fields=name&limit=2&page=1>; rel="first", </users?fields=name&limit=2&page=1>; rel="prev", </users?fields=name&limit=2&page=3>; rel="next", </users?fields=name&limit=2&page=3>; rel="last"`, c.GetHeader(elton.HeaderLink))
+
+	// 无分页
+	req = httptest.NewRequest("GET", "/users/1", nil)
+	c = elton.NewContext(httptest.NewRecorder(), req)
+	c.Next = func() error {
+		c.Body = map[string]string{
+			"id": "1",
+		}
+		return nil
+	}
+	err = fn(c)
+	assert.Nil(err)
+	assert.Equal(`{"data":{"id":"1"}}`, c.BodyBuffer.String())
+	assert.Empty(c.GetHeader(elton.HeaderLink))
+
+	// 出错则返回由error handler处理
+	req = httptest.NewRequest("GET", "/users?page=a", nil)
+	c = elton.NewContext(httptest.NewRecorder(), req)
+	c.Next = func() error {
+		_, err := c.ParsePagination(10, 100)
+		return err
+	}
+	err = fn(c)
+	assert.NotNil(err)
+	assert.Equal("statusCode=400, category=elton, message=invalid pagination", err.Error())
+	assert.Nil(c.BodyBuffer)
+}
@@ -26,7 +26,12 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	gopath "path"
+	"sort"
+	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/vicanso/elton"
 	"github.com/vicanso/hes"
@@ -57,54 +62,200 @@ type (
 		DecConcurrency(route string)
 		GetConcurrency(route string) (current uint32)
 	}
+	// RCLObserver observer interface, if the limiter implements it,
+	// the latency and error of request will be observed after the request is done
+	RCLObserver interface {
+		Observe(route string, latency time.Duration, err error)
+	}
 	// LocalLimiter local limiter
 	RCLLocalLimiter struct {
+		mutex sync.RWMutex
+		// m concurrency of routes
 		m map[string]*rclConcurrency
+		// limits the limit of route or pattern
+		limits map[string]uint32
+		// patterns sorted by length desc
+		patterns []string
+	}
+	// RCLRouteStats stats of route
+	RCLRouteStats struct {
+		Route   string `json:"route"`
+		Current uint32 `json:"current"`
+		Max     uint32 `json:"max"`
 	}
 )
 
 // NewLocalLimiter returns a new local limiter, it's useful for limit concurrency for process.
+// The route can be a pattern(path.Match), e.g. "GET /users/*",
+// the exact route has higher priority than the pattern, and the longer pattern has higher priority.
 func NewLocalLimiter(data map[string]uint32) *RCLLocalLimiter {
-	m := make(map[string]*rclConcurrency, len(data))
+	l := &RCLLocalLimiter{}
+	l.Update(data)
+	return l
+}
+
+func isRCLPattern(route string) bool {
+	return strings.ContainsAny(route, "*?[")
+}
+
+// match returns the limit of route, it should be called with lock
+func (l *RCLLocalLimiter) match(route string) (uint32, bool) {
+	max, ok := l.limits[route]
+	if ok {
+		return max, true
+	}
+	for _, pattern := range l.patterns {
+		if matched, _ := gopath.Match(pattern, route); matched {
+			return l.limits[pattern], true
+		}
+	}
+	return 0, false
+}
+
+// Update updates all limits of routes, the concurrency of the route which is still limited will be kept,
+// and the route which is not in the limits will be removed.
+func (l *RCLLocalLimiter) Update(data map[string]uint32) {
+	limits := make(map[string]uint32, len(data))
+	patterns := make([]string, 0)
 	for route, max := range data {
-		m[route] = &rclConcurrency{
-			max:     max,
-			current: 0,
+		limits[route] = max
+		if isRCLPattern(route) {
+			patterns = append(patterns, route)
 		}
 	}
-	return &RCLLocalLimiter{
-		m: m,
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.limits = limits
+	l.patterns = patterns
+	m := make(map[string]*rclConcurrency, len(limits))
+	// 保留仍有限制的路由的并发数
+	for route, concur := range l.m {
+		max, ok := l.match(route)
+		if ok {
+			atomic.StoreUint32(&concur.max, max)
+			m[route] = concur
+		}
+	}
+	for route, max := range limits {
+		if isRCLPattern(route) {
+			continue
+		}
+		if _, ok := m[route]; !ok {
+			m[route] = &rclConcurrency{
+				max: max,
+			}
+		}
 	}
+	l.m = m
 }
 
-// IncConcurrency inc 1
-func (l *RCLLocalLimiter) IncConcurrency(key string) (current, max uint32) {
+// SetLimit sets the limit of route or pattern
+func (l *RCLLocalLimiter) SetLimit(route string, max uint32) {
+	data := l.Limits()
+	data[route] = max
+	l.Update(data)
+}
+
+// RemoveLimit removes the limit of route or pattern
+func (l *RCLLocalLimiter) RemoveLimit(route string) {
+	data := l.Limits()
+	delete(data, route)
+	l.Update(data)
+}
+
+// Limits returns the limits of routes and patterns
+func (l *RCLLocalLimiter) Limits() map[string]uint32 {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	data := make(map[string]uint32, len(l.limits))
+	for route, max := range l.limits {
+		data[route] = max
+	}
+	return data
+}
+
+func (l *RCLLocalLimiter) getConcurrency(key string, create bool) *rclConcurrency {
+	l.mutex.RLock()
 	concur, ok := l.m[key]
+	if ok || !create || len(l.patterns) == 0 {
+		l.mutex.RUnlock()
+		return concur
+	}
+	max, matched := l.match(key)
+	l.mutex.RUnlock()
+	if !matched {
+		return nil
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	concur, ok = l.m[key]
 	if !ok {
+		concur = &rclConcurrency{
+			max: max,
+		}
+		l.m[key] = concur
+	}
+	return concur
+}
+
+// IncConcurrency inc 1
+func (l *RCLLocalLimiter) IncConcurrency(key string) (current, max uint32) {
+	concur := l.getConcurrency(key, true)
+	if concur == nil {
 		return 0, 0
 	}
 	v := atomic.AddUint32(&concur.current, 1)
-	return v, concur.max
+	return v, atomic.LoadUint32(&concur.max)
 }
 
 // DecConcurrency dec 1
 func (l *RCLLocalLimiter) DecConcurrency(key string) {
-	concur, ok := l.m[key]
-	if !ok {
+	concur := l.getConcurrency(key, false)
+	if concur == nil {
 		return
 	}
-	atomic.AddUint32(&concur.current, ^uint32(0))
+	// 路由的限制有可能在请求处理中添加，因此避免小于0
+	for {
+		v := atomic.LoadUint32(&concur.current)
+		if v == 0 || atomic.CompareAndSwapUint32(&concur.current, v, v-1) {
+			return
+		}
+	}
 }
 
 // GetConcurrency value
 func (l *RCLLocalLimiter) GetConcurrency(key string) uint32 {
-	concur, ok := l.m[key]
-	if !ok {
+	concur := l.getConcurrency(key, false)
+	if concur == nil {
 		return 0
 	}
 	return atomic.LoadUint32(&concur.current)
 }
 
+// Snapshot returns the current and max concurrency of all limited routes, it's sorted by route
+func (l *RCLLocalLimiter) Snapshot() []RCLRouteStats {
+	l.mutex.RLock()
+	result := make([]RCLRouteStats, 0, len(l.m))
+	for route, concur := range l.m {
+		result = append(result, RCLRouteStats{
+			Route:   route,
+			Current: atomic.LoadUint32(&concur.current),
+			Max:     atomic.LoadUint32(&concur.max),
+		})
+	}
+	l.mutex.RUnlock()
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Route < result[j].Route
+	})
+	return result
+}
+
 func createRCLError(current, max uint32) error {
 	he := hes.New(fmt.Sprintf("too many request, current:%d, max:%d", current, max))
 	he.Category = ErrRCLCategory
@@ -123,6 +274,7 @@ func NewRCL(config RCLConfig) elton.Handler {
 		panic(ErrRCLRequireLimiter)
 	}
 	limiter := config.Limiter
+	observer, _ := limiter.(RCLObserver)
 	return func(c *elton.Context) (err error) {
 		if skipper(c) {
 			return c.Next()
@@ -134,6 +286,12 @@ func NewRCL(config RCLConfig) elton.Handler {
 			err = createRCLError(current, max)
 			return
 		}
-		return c.Next()
+		if observer == nil {
+			return c.Next()
+		}
+		startedAt := time.Now()
+		err = c.Next()
+		observer.Observe(key, time.Since(startedAt), err)
+		return
 	}
 }
@@ -0,0 +1,279 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vicanso/hes"
+)
+
+const (
+	// RCLAlgorithmAIMD additive increase multiplicative decrease algorithm
+	RCLAlgorithmAIMD = "aimd"
+	// RCLAlgorithmGradient gradient algorithm, it adjusts limit by the gradient of latency
+	RCLAlgorithmGradient = "gradient"
+
+	defaultRCLInitialLimit = 20
+	defaultRCLMinLimit     = 1
+	defaultRCLMaxLimit     = 1000
+	defaultRCLBackoffRatio = 0.9
+	defaultRCLTolerance    = 1.5
+	defaultRCLSmoothing    = 0.2
+	// 长期延时的平滑窗口
+	rclLongWindow = 600
+)
+
+var (
+	// ErrRCLInvalidAlgorithm invalid algorithm
+	ErrRCLInvalidAlgorithm = errors.New("algorithm should be aimd or gradient")
+)
+
+type (
+	// RCLAdaptiveLimiterConfig adaptive limiter config
+	RCLAdaptiveLimiterConfig struct {
+		// Algorithm aimd or gradient, default is aimd
+		Algorithm string
+		// InitialLimit initial limit of route, default is 20
+		InitialLimit uint32
+		// MinLimit min limit of route, default is 1
+		MinLimit uint32
+		// MaxLimit max limit of route, default is 1000
+		MaxLimit uint32
+		// BackoffRatio the ratio of limit decrease when overload, default is 0.9
+		BackoffRatio float64
+		// LatencyThreshold the request is treated as overload if the latency is greater than it(aimd),
+		// zero means only server error is treated as overload
+		LatencyThreshold time.Duration
+		// Tolerance the tolerance of latency(gradient), default is 1.5
+		Tolerance float64
+		// Smoothing the smoothing factor of limit(gradient), default is 0.2
+		Smoothing float64
+	}
+	// RCLAdaptiveRouteStats stats of route
+	RCLAdaptiveRouteStats struct {
+		Route   string  `json:"route"`
+		Current uint32  `json:"current"`
+		Limit   uint32  `json:"limit"`
+		Latency float64 `json:"latency"`
+	}
+	rclAdaptiveRoute struct {
+		current uint32
+		mutex   sync.Mutex
+		limit   float64
+		// 长期的平均延时(ns)
+		longLatency float64
+		samples     int
+	}
+	// RCLAdaptiveLimiter adaptive limiter, it adjusts the limit of each route by the latency and error
+	RCLAdaptiveLimiter struct {
+		mutex  sync.RWMutex
+		m      map[string]*rclAdaptiveRoute
+		config RCLAdaptiveLimiterConfig
+	}
+)
+
+var _ RCLLimiter = (*RCLAdaptiveLimiter)(nil)
+var _ RCLObserver = (*RCLAdaptiveLimiter)(nil)
+
+// NewAdaptiveLimiter returns a new adaptive limiter, the limit of route will be adjusted automatically,
+// it will throw a panic if the algorithm is invalid.
+func NewAdaptiveLimiter(config RCLAdaptiveLimiterConfig) *RCLAdaptiveLimiter {
+	if config.Algorithm == "" {
+		config.Algorithm = RCLAlgorithmAIMD
+	}
+	if config.Algorithm != RCLAlgorithmAIMD && config.Algorithm != RCLAlgorithmGradient {
+		panic(ErrRCLInvalidAlgorithm)
+	}
+	if config.MinLimit == 0 {
+		config.MinLimit = defaultRCLMinLimit
+	}
+	if config.MaxLimit == 0 {
+		config.MaxLimit = defaultRCLMaxLimit
+	}
+	if config.InitialLimit == 0 {
+		config.InitialLimit = defaultRCLInitialLimit
+	}
+	if config.InitialLimit < config.MinLimit {
+		config.InitialLimit = config.MinLimit
+	}
+	if config.InitialLimit > config.MaxLimit {
+		config.InitialLimit = config.MaxLimit
+	}
+	if config.BackoffRatio <= 0 || config.BackoffRatio >= 1 {
+		config.BackoffRatio = defaultRCLBackoffRatio
+	}
+	if config.Tolerance < 1 {
+		config.Tolerance = defaultRCLTolerance
+	}
+	if config.Smoothing <= 0 || config.Smoothing > 1 {
+		config.Smoothing = defaultRCLSmoothing
+	}
+	return &RCLAdaptiveLimiter{
+		m:      make(map[string]*rclAdaptiveRoute),
+		config: config,
+	}
+}
+
+func (l *RCLAdaptiveLimiter) getRoute(route string, create bool) *rclAdaptiveRoute {
+	l.mutex.RLock()
+	r, ok := l.m[route]
+	l.mutex.RUnlock()
+	if ok || !create {
+		return r
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	r, ok = l.m[route]
+	if !ok {
+		r = &rclAdaptiveRoute{
+			limit: float64(l.config.InitialLimit),
+		}
+		l.m[route] = r
+	}
+	return r
+}
+
+// IncConcurrency inc 1
+func (l *RCLAdaptiveLimiter) IncConcurrency(route string) (current, max uint32) {
+	r := l.getRoute(route, true)
+	v := atomic.AddUint32(&r.current, 1)
+	r.mutex.Lock()
+	max = uint32(r.limit)
+	r.mutex.Unlock()
+	return v, max
+}
+
+// DecConcurrency dec 1
+func (l *RCLAdaptiveLimiter) DecConcurrency(route string) {
+	r := l.getRoute(route, false)
+	if r == nil {
+		return
+	}
+	atomic.AddUint32(&r.current, ^uint32(0))
+}
+
+// GetConcurrency value
+func (l *RCLAdaptiveLimiter) GetConcurrency(route string) uint32 {
+	r := l.getRoute(route, false)
+	if r == nil {
+		return 0
+	}
+	return atomic.LoadUint32(&r.current)
+}
+
+// GetLimit returns the current limit of route
+func (l *RCLAdaptiveLimiter) GetLimit(route string) uint32 {
+	r := l.getRoute(route, false)
+	if r == nil {
+		return l.config.InitialLimit
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return uint32(r.limit)
+}
+
+// Snapshot returns the stats of all routes, it's sorted by route
+func (l *RCLAdaptiveLimiter) Snapshot() []RCLAdaptiveRouteStats {
+	l.mutex.RLock()
+	result := make([]RCLAdaptiveRouteStats, 0, len(l.m))
+	for route, r := range l.m {
+		r.mutex.Lock()
+		result = append(result, RCLAdaptiveRouteStats{
+			Route:   route,
+			Current: atomic.LoadUint32(&r.current),
+			Limit:   uint32(r.limit),
+			Latency: time.Duration(r.longLatency).Seconds() * 1000,
+		})
+		r.mutex.Unlock()
+	}
+	l.mutex.RUnlock()
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Route < result[j].Route
+	})
+	return result
+}
+
+func isServerError(err error) bool {
+	if err == nil {
+		return false
+	}
+	he, ok := err.(*hes.Error)
+	if !ok {
+		return true
+	}
+	return he.StatusCode >= http.StatusInternalServerError
+}
+
+// Observe observes the latency and error of request, and adjusts the limit of route
+func (l *RCLAdaptiveLimiter) Observe(route string, latency time.Duration, err error) {
+	r := l.getRoute(route, false)
+	if r == nil {
+		return
+	}
+	conf := l.config
+	current := float64(atomic.LoadUint32(&r.current))
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	limit := r.limit
+	sample := float64(latency)
+	// 更新长期的平均延时
+	r.samples++
+	window := r.samples
+	if window > rclLongWindow {
+		window = rclLongWindow
+	}
+	r.longLatency += (sample - r.longLatency) / float64(window)
+
+	if isServerError(err) {
+		limit *= conf.BackoffRatio
+	} else if conf.Algorithm == RCLAlgorithmGradient {
+		// 如果长期延时远大于当前延时(如负载降低后)，则逐渐降低长期延时
+		if sample > 0 && r.longLatency/sample > 2 {
+			r.longLatency *= 0.95
+		}
+		gradient := 1.0
+		if sample > 0 {
+			gradient = math.Max(0.5, math.Min(1, conf.Tolerance*r.longLatency/sample))
+		}
+		newLimit := limit*gradient + math.Sqrt(limit)
+		newLimit = limit*(1-conf.Smoothing) + newLimit*conf.Smoothing
+		// 并发量未达到限制的一半时不增加
+		if newLimit < limit || current*2 >= limit {
+			limit = newLimit
+		}
+	} else if conf.LatencyThreshold > 0 && latency > conf.LatencyThreshold {
+		limit *= conf.BackoffRatio
+	} else if current*2 >= limit {
+		// 仅当并发量达到限制的一半时才增加，避免空闲时无限增加
+		limit++
+	}
+	limit = math.Max(float64(conf.MinLimit), math.Min(float64(conf.MaxLimit), limit))
+	r.limit = limit
+}
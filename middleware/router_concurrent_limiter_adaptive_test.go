@@ -0,0 +1,150 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vicanso/elton"
+	"github.com/vicanso/hes"
+)
+
+func TestAdaptiveLimiterInvalidAlgorithm(t *testing.T) {
+	assert := assert.New(t)
+	defer func() {
+		r := recover()
+		assert.Equal(ErrRCLInvalidAlgorithm, r.(error))
+	}()
+	NewAdaptiveLimiter(RCLAdaptiveLimiterConfig{
+		Algorithm: "a",
+	})
+}
+
+func TestAdaptiveLimiterAIMD(t *testing.T) {
+	assert := assert.New(t)
+	route := "GET /users/me"
+	limiter := NewAdaptiveLimiter(RCLAdaptiveLimiterConfig{
+		InitialLimit:     4,
+		MinLimit:         2,
+		MaxLimit:         5,
+		LatencyThreshold: 100 * time.Millisecond,
+	})
+	assert.Equal(uint32(4), limiter.GetLimit(route))
+	// 未有请求的路由不处理
+	limiter.Observe(route, time.Millisecond, nil)
+	limiter.DecConcurrency(route)
+	assert.Equal(uint32(0), limiter.GetConcurrency(route))
+
+	limiter.IncConcurrency(route)
+	current, max := limiter.IncConcurrency(route)
+	assert.Equal(uint32(2), current)
+	assert.Equal(uint32(4), max)
+
+	limiter.Observe(route, time.Millisecond, nil)
+	assert.Equal(uint32(5), limiter.GetLimit(route))
+	// 不超过最大值
+	limiter.Observe(route, time.Millisecond, nil)
+	assert.Equal(uint32(5), limiter.GetLimit(route))
+
+	// 出错时减少
+	limiter.Observe(route, time.Millisecond, errors.New("error"))
+	assert.Equal(uint32(4), limiter.GetLimit(route))
+
+	// 客户端出错不减少（并发量未达到限制的一半也不增加）
+	limiter.Observe(route, time.Millisecond, hes.New("invalid params"))
+	assert.Equal(uint32(4), limiter.GetLimit(route))
+
+	// 延时过高时减少
+	for i := 0; i < 10; i++ {
+		limiter.Observe(route, time.Second, nil)
+	}
+	assert.Equal(uint32(2), limiter.GetLimit(route))
+
+	limiter.DecConcurrency(route)
+	assert.Equal([]RCLAdaptiveRouteStats{
+		{
+			Route:   route,
+			Current: 1,
+			Limit:   2,
+			Latency: limiter.Snapshot()[0].Latency,
+		},
+	}, limiter.Snapshot())
+	assert.NotEmpty(limiter.Snapshot()[0].Latency)
+}
+
+func TestAdaptiveLimiterGradient(t *testing.T) {
+	assert := assert.New(t)
+	route := "GET /users/me"
+	limiter := NewAdaptiveLimiter(RCLAdaptiveLimiterConfig{
+		Algorithm:    RCLAlgorithmGradient,
+		InitialLimit: 10,
+		MaxLimit:     100,
+	})
+	for i := 0; i < 10; i++ {
+		limiter.IncConcurrency(route)
+	}
+	// 延时稳定时增加
+	for i := 0; i < 20; i++ {
+		limiter.Observe(route, 10*time.Millisecond, nil)
+	}
+	increased := limiter.GetLimit(route)
+	assert.True(increased > 10)
+
+	// 延时增加时减少
+	for i := 0; i < 20; i++ {
+		limiter.Observe(route, 100*time.Millisecond, nil)
+	}
+	assert.True(limiter.GetLimit(route) < increased)
+}
+
+func TestRCLObserve(t *testing.T) {
+	assert := assert.New(t)
+	limiter := NewAdaptiveLimiter(RCLAdaptiveLimiterConfig{
+		InitialLimit: 1,
+		MinLimit:     1,
+	})
+	fn := NewRCL(RCLConfig{
+		Limiter: limiter,
+	})
+	customErr := errors.New("custom error")
+	newContext := func() *elton.Context {
+		c := elton.NewContext(nil, httptest.NewRequest("GET", "/", nil))
+		c.Route = "/"
+		c.Next = func() error {
+			// 处理中时其它请求被拒绝
+			other := elton.NewContext(nil, c.Request)
+			other.Route = "/"
+			assert.Equal(createRCLError(2, 1), fn(other))
+			return customErr
+		}
+		return c
+	}
+	assert.Equal(customErr, fn(newContext()))
+	snapshot := limiter.Snapshot()
+	assert.Equal(1, len(snapshot))
+	assert.Equal(uint32(0), snapshot[0].Current)
+}
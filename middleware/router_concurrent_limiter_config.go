@@ -0,0 +1,141 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vicanso/elton"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// DefaultRCLWatchInterval default interval of watching config file
+	DefaultRCLWatchInterval = 5 * time.Second
+)
+
+var (
+	// ErrRCLInvalidConfigFile invalid config file
+	ErrRCLInvalidConfigFile = errors.New("config file should be json or yaml")
+)
+
+// LoadRCLLimits loads the limits of routes from json or yaml file, e.g.:
+// {"GET /users/*": 100, "POST /users/login": 10}
+func LoadRCLLimits(file string) (map[string]uint32, error) {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	data := make(map[string]uint32)
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".json":
+		err = json.Unmarshal(buf, &data)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(buf, &data)
+	default:
+		err = ErrRCLInvalidConfigFile
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// WatchFile loads the limits from config file and watches it, the limits will be updated when the file is changed.
+// The file is checked by modified time and size every interval(default is 5 seconds),
+// the onError function will be called if the file is failed to load.
+// It returns a stop function to stop watching.
+func (l *RCLLocalLimiter) WatchFile(file string, interval time.Duration, onError func(error)) (stop func(), err error) {
+	data, err := LoadRCLLimits(file)
+	if err != nil {
+		return
+	}
+	l.Update(data)
+	info, err := os.Stat(file)
+	if err != nil {
+		return
+	}
+	if interval <= 0 {
+		interval = DefaultRCLWatchInterval
+	}
+	modTime := info.ModTime()
+	size := info.Size()
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			info, e := os.Stat(file)
+			if e == nil && info.ModTime().Equal(modTime) && info.Size() == size {
+				continue
+			}
+			var data map[string]uint32
+			if e == nil {
+				modTime = info.ModTime()
+				size = info.Size()
+				data, e = LoadRCLLimits(file)
+			}
+			if e != nil {
+				if onError != nil {
+					onError(e)
+				}
+				continue
+			}
+			l.Update(data)
+		}
+	}()
+	once := sync.Once{}
+	stop = func() {
+		once.Do(func() {
+			close(done)
+		})
+	}
+	return
+}
+
+// NewRCLSnapshot returns a handler which responses the current and max concurrency of all limited routes(json)
+func NewRCLSnapshot(l *RCLLocalLimiter) elton.Handler {
+	return func(c *elton.Context) error {
+		buf, err := json.Marshal(l.Snapshot())
+		if err != nil {
+			return err
+		}
+		c.NoCache()
+		c.SetHeader(elton.HeaderContentType, elton.MIMEApplicationJSON)
+		c.BodyBuffer = bytes.NewBuffer(buf)
+		return nil
+	}
+}
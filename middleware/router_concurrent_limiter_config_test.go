@@ -0,0 +1,160 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vicanso/elton"
+)
+
+func TestRCLLocalLimiterUpdate(t *testing.T) {
+	assert := assert.New(t)
+	limiter := NewLocalLimiter(map[string]uint32{
+		"GET /users/*":      10,
+		"GET /users/me":     1,
+		"POST /users/login": 5,
+	})
+
+	cur, max := limiter.IncConcurrency("GET /users/:id")
+	assert.Equal(uint32(1), cur)
+	assert.Equal(uint32(10), max)
+	// 精确匹配优先
+	_, max = limiter.IncConcurrency("GET /users/me")
+	assert.Equal(uint32(1), max)
+	_, max = limiter.IncConcurrency("GET /books/:id")
+	assert.Equal(uint32(0), max)
+
+	limiter.SetLimit("GET /books/:id", 3)
+	limiter.SetLimit("GET /users/*", 20)
+	limiter.RemoveLimit("POST /users/login")
+	assert.Equal(map[string]uint32{
+		"GET /users/*":   20,
+		"GET /users/me":  1,
+		"GET /books/:id": 3,
+	}, limiter.Limits())
+
+	// 添加限制前的请求完成，不会小于0
+	limiter.DecConcurrency("GET /books/:id")
+	assert.Equal([]RCLRouteStats{
+		{
+			Route: "GET /books/:id",
+			Max:   3,
+		},
+		{
+			Route:   "GET /users/:id",
+			Current: 1,
+			Max:     20,
+		},
+		{
+			Route:   "GET /users/me",
+			Current: 1,
+			Max:     1,
+		},
+	}, limiter.Snapshot())
+
+	// 删除后不再限制
+	limiter.RemoveLimit("GET /users/*")
+	cur, max = limiter.IncConcurrency("GET /users/:id")
+	assert.Equal(uint32(0), cur)
+	assert.Equal(uint32(0), max)
+}
+
+func TestLoadRCLLimits(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+
+	jsonFile := filepath.Join(dir, "limits.json")
+	assert.Nil(ioutil.WriteFile(jsonFile, []byte(`{"GET /users/*": 10}`), 0600))
+	data, err := LoadRCLLimits(jsonFile)
+	assert.Nil(err)
+	assert.Equal(map[string]uint32{
+		"GET /users/*": 10,
+	}, data)
+
+	yamlFile := filepath.Join(dir, "limits.yml")
+	assert.Nil(ioutil.WriteFile(yamlFile, []byte(`"POST /users/login": 5`), 0600))
+	data, err = LoadRCLLimits(yamlFile)
+	assert.Nil(err)
+	assert.Equal(map[string]uint32{
+		"POST /users/login": 5,
+	}, data)
+
+	txtFile := filepath.Join(dir, "limits.txt")
+	assert.Nil(ioutil.WriteFile(txtFile, []byte(""), 0600))
+	_, err = LoadRCLLimits(txtFile)
+	assert.Equal(ErrRCLInvalidConfigFile, err)
+
+	_, err = LoadRCLLimits(filepath.Join(dir, "not-exists.json"))
+	assert.True(os.IsNotExist(err))
+}
+
+func TestRCLLocalLimiterWatchFile(t *testing.T) {
+	assert := assert.New(t)
+	file := filepath.Join(t.TempDir(), "limits.json")
+	assert.Nil(ioutil.WriteFile(file, []byte(`{"GET /users/*": 10}`), 0600))
+
+	limiter := NewLocalLimiter(nil)
+	errs := make(chan error, 10)
+	stop, err := limiter.WatchFile(file, 5*time.Millisecond, func(err error) {
+		errs <- err
+	})
+	assert.Nil(err)
+	defer stop()
+	assert.Equal(map[string]uint32{
+		"GET /users/*": 10,
+	}, limiter.Limits())
+
+	assert.Nil(ioutil.WriteFile(file, []byte(`{"GET /users/*": 20, "GET /books": 1}`), 0600))
+	assert.Eventually(func() bool {
+		return len(limiter.Limits()) == 2
+	}, time.Second, 5*time.Millisecond)
+
+	// 配置出错时保留原有配置
+	assert.Nil(ioutil.WriteFile(file, []byte(`{`), 0600))
+	select {
+	case err := <-errs:
+		assert.NotNil(err)
+	case <-time.After(time.Second):
+		assert.Fail("should get error")
+	}
+	assert.Equal(uint32(20), limiter.Limits()["GET /users/*"])
+}
+
+func TestRCLSnapshot(t *testing.T) {
+	assert := assert.New(t)
+	limiter := NewLocalLimiter(map[string]uint32{
+		"GET /users/me": 1,
+	})
+	fn := NewRCLSnapshot(limiter)
+	c := elton.NewContext(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	assert.Nil(fn(c))
+	assert.Equal(elton.MIMEApplicationJSON, c.GetHeader(elton.HeaderContentType))
+	assert.Equal(`[{"route":"GET /users/me","current":0,"max":1}]`, c.BodyBuffer.String())
+}
@@ -0,0 +1,321 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vicanso/elton"
+	"github.com/vicanso/hes"
+)
+
+const (
+	// ErrSignatureAuthCategory signature auth error category
+	ErrSignatureAuthCategory = "elton-signature-auth"
+	// DefaultSignatureAccessKeyHeader default header of access key
+	DefaultSignatureAccessKeyHeader = "X-Access-Key"
+	// DefaultSignatureTimestampHeader default header of timestamp(unix seconds)
+	DefaultSignatureTimestampHeader = "X-Timestamp"
+	// DefaultSignatureNonceHeader default header of nonce
+	DefaultSignatureNonceHeader = "X-Nonce"
+	// DefaultSignatureHeader default header of signature
+	DefaultSignatureHeader = "X-Signature"
+	// DefaultSignatureClockSkew default clock skew(5 minutes)
+	DefaultSignatureClockSkew = 5 * time.Minute
+)
+
+type (
+	// SignatureSecretLookup lookup function, it returns the secret of access key,
+	// the access key is invalid if secret is empty
+	SignatureSecretLookup func(accessKey string, c *elton.Context) (secret string, err error)
+	// SignatureNonceStore nonce store, it's used to prevent replay attack
+	SignatureNonceStore interface {
+		// SetNX sets the nonce if it's not exists, returns false if the nonce exists
+		SetNX(nonce string, ttl time.Duration) (bool, error)
+	}
+	// SignatureAuthConfig signature auth config
+	SignatureAuthConfig struct {
+		// AccessKeyHeader the header name of access key, default is X-Access-Key
+		AccessKeyHeader string
+		// TimestampHeader the header name of timestamp, default is X-Timestamp
+		TimestampHeader string
+		// NonceHeader the header name of nonce, default is X-Nonce
+		NonceHeader string
+		// SignatureHeader the header name of signature, default is X-Signature
+		SignatureHeader string
+		// ClockSkew the max clock skew of timestamp, default is 5 minutes
+		ClockSkew time.Duration
+		// Limit the limit size of body, default is 50KB, no limit if it's < 0
+		Limit int
+		// Lookup lookup secret function
+		Lookup SignatureSecretLookup
+		// NonceStore nonce store, default is local nonce store
+		NonceStore SignatureNonceStore
+		// IdentityKey the key of identity for context, default is authIdentity
+		IdentityKey string
+		Skipper     elton.Skipper
+	}
+	// LocalNonceStore local nonce store
+	LocalNonceStore struct {
+		mutex     sync.Mutex
+		m         map[string]time.Time
+		nextPrune time.Time
+	}
+)
+
+var (
+	// ErrSignatureMissing signature is missing
+	ErrSignatureMissing = getSignatureAuthError("signature is missing")
+	// ErrSignatureExpired signature is expired
+	ErrSignatureExpired = getSignatureAuthError("signature is expired")
+	// ErrSignatureReplay signature is replayed
+	ErrSignatureReplay = getSignatureAuthError("signature has been used")
+	// ErrSignatureInvalid signature is invalid
+	ErrSignatureInvalid = getSignatureAuthError("signature is invalid")
+	// ErrSignatureRequireLookupFunction require lookup function
+	ErrSignatureRequireLookupFunction = errors.New("require lookup function")
+)
+
+func getSignatureAuthError(message string) *hes.Error {
+	return &hes.Error{
+		StatusCode: http.StatusUnauthorized,
+		Message:    message,
+		Category:   ErrSignatureAuthCategory,
+	}
+}
+
+// NewLocalNonceStore returns a new local nonce store
+func NewLocalNonceStore() *LocalNonceStore {
+	return &LocalNonceStore{
+		m: make(map[string]time.Time),
+	}
+}
+
+// SetNX sets the nonce if it's not exists or expired
+func (s *LocalNonceStore) SetNX(nonce string, ttl time.Duration) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now()
+	// 定期清除已过期的nonce
+	if now.After(s.nextPrune) {
+		for key, expiredAt := range s.m {
+			if now.After(expiredAt) {
+				delete(s.m, key)
+			}
+		}
+		s.nextPrune = now.Add(ttl)
+	}
+	expiredAt, ok := s.m[nonce]
+	if ok && now.Before(expiredAt) {
+		return false, nil
+	}
+	s.m[nonce] = now.Add(ttl)
+	return true, nil
+}
+
+// GetCanonicalRequest returns the canonical request for signature,
+// it's joined by method, path, sorted query, sha256 of body, timestamp and nonce.
+func GetCanonicalRequest(method, path string, query url.Values, body []byte, timestamp, nonce string) string {
+	hash := sha256.Sum256(body)
+	return strings.Join([]string{
+		strings.ToUpper(method),
+		path,
+		// encode会根据key排序
+		query.Encode(),
+		hex.EncodeToString(hash[:]),
+		timestamp,
+		nonce,
+	}, "\n")
+}
+
+// SignCanonicalRequest returns the hmac sha256 signature(hex) of canonical request
+func SignCanonicalRequest(secret, canonicalRequest string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write([]byte(canonicalRequest))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignHTTPRequest signs the http request and sets the headers of signature,
+// it's useful for client to call the service using signature auth.
+func SignHTTPRequest(req *http.Request, accessKey, secret string, body []byte) error {
+	buf := make([]byte, 16)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return err
+	}
+	nonce := hex.EncodeToString(buf)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	canonicalRequest := GetCanonicalRequest(req.Method, req.URL.Path, req.URL.Query(), body, timestamp, nonce)
+	req.Header.Set(DefaultSignatureAccessKeyHeader, accessKey)
+	req.Header.Set(DefaultSignatureTimestampHeader, timestamp)
+	req.Header.Set(DefaultSignatureNonceHeader, nonce)
+	req.Header.Set(DefaultSignatureHeader, SignCanonicalRequest(secret, canonicalRequest))
+	return nil
+}
+
+// readSignatureBody reads the body of request and resets it,
+// so the body parser middleware can read the body again.
+func readSignatureBody(c *elton.Context, limit int) ([]byte, error) {
+	if c.RequestBody != nil {
+		return c.RequestBody, nil
+	}
+	r := c.Request.Body
+	if r == nil || r == http.NoBody {
+		return nil, nil
+	}
+	if limit > 0 {
+		r = MaxBytesReader(r, int64(limit))
+	}
+	defer r.Close()
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// NewSignatureAuth returns a new signature auth middleware,
+// it validates the hmac sha256 signature of canonical request(method, path, sorted query, body hash, timestamp and nonce).
+// The timestamp should be in the clock skew and the nonce can only be used once.
+// It should be added before body parser middleware, because the signature is calculated from the original body.
+// The access key of caller will be set to context, it can be got by c.GetString(IdentityKey).
+// It will throw a panic if the lookup function is nil.
+func NewSignatureAuth(config SignatureAuthConfig) elton.Handler {
+	if config.Lookup == nil {
+		panic(ErrSignatureRequireLookupFunction)
+	}
+	getValue := func(value, defaultValue string) string {
+		if value == "" {
+			return defaultValue
+		}
+		return value
+	}
+	accessKeyHeader := getValue(config.AccessKeyHeader, DefaultSignatureAccessKeyHeader)
+	timestampHeader := getValue(config.TimestampHeader, DefaultSignatureTimestampHeader)
+	nonceHeader := getValue(config.NonceHeader, DefaultSignatureNonceHeader)
+	signatureHeader := getValue(config.SignatureHeader, DefaultSignatureHeader)
+	identityKey := getValue(config.IdentityKey, DefaultAuthIdentityKey)
+	clockSkew := config.ClockSkew
+	if clockSkew <= 0 {
+		clockSkew = DefaultSignatureClockSkew
+	}
+	limit := defaultRequestBodyLimit
+	if config.Limit != 0 {
+		limit = config.Limit
+	}
+	nonceStore := config.NonceStore
+	if nonceStore == nil {
+		nonceStore = NewLocalNonceStore()
+	}
+	skipper := config.Skipper
+	if skipper == nil {
+		skipper = elton.DefaultSkipper
+	}
+	return func(c *elton.Context) (err error) {
+		if skipper(c) || c.Request.Method == http.MethodOptions {
+			return c.Next()
+		}
+		accessKey := c.GetRequestHeader(accessKeyHeader)
+		timestamp := c.GetRequestHeader(timestampHeader)
+		nonce := c.GetRequestHeader(nonceHeader)
+		signature := c.GetRequestHeader(signatureHeader)
+		if accessKey == "" || timestamp == "" || nonce == "" || signature == "" {
+			err = ErrSignatureMissing
+			return
+		}
+		seconds, e := strconv.ParseInt(timestamp, 10, 64)
+		if e != nil {
+			err = ErrSignatureInvalid
+			return
+		}
+		offset := time.Since(time.Unix(seconds, 0))
+		if offset < 0 {
+			offset = -offset
+		}
+		if offset > clockSkew {
+			err = ErrSignatureExpired
+			return
+		}
+
+		secret, e := config.Lookup(accessKey, c)
+		if e != nil {
+			he, ok := e.(*hes.Error)
+			if !ok {
+				he = hes.Wrap(e)
+				he.StatusCode = http.StatusBadRequest
+				he.Category = ErrSignatureAuthCategory
+			}
+			err = he
+			return
+		}
+		if secret == "" {
+			err = ErrSignatureInvalid
+			return
+		}
+		body, e := readSignatureBody(c, limit)
+		if e != nil {
+			he, ok := e.(*hes.Error)
+			if !ok {
+				he = hes.Wrap(e)
+				he.StatusCode = http.StatusBadRequest
+				he.Category = ErrSignatureAuthCategory
+			}
+			err = he
+			return
+		}
+		req := c.Request
+		canonicalRequest := GetCanonicalRequest(req.Method, req.URL.Path, req.URL.Query(), body, timestamp, nonce)
+		expected := SignCanonicalRequest(secret, canonicalRequest)
+		if !hmac.Equal([]byte(expected), []byte(strings.ToLower(signature))) {
+			err = ErrSignatureInvalid
+			return
+		}
+
+		// 签名校验成功后才记录nonce，避免非法请求占用nonce
+		// nonce的有效期需要覆盖时间戳前后的偏差
+		success, e := nonceStore.SetNX(accessKey+":"+nonce, 2*clockSkew)
+		if e != nil {
+			err = hes.Wrap(e)
+			return
+		}
+		if !success {
+			err = ErrSignatureReplay
+			return
+		}
+		c.Set(identityKey, accessKey)
+		return c.Next()
+	}
+}
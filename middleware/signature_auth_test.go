@@ -0,0 +1,135 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vicanso/elton"
+)
+
+func TestLocalNonceStore(t *testing.T) {
+	assert := assert.New(t)
+	store := NewLocalNonceStore()
+	success, err := store.SetNX("a", 10*time.Millisecond)
+	assert.Nil(err)
+	assert.True(success)
+
+	success, err = store.SetNX("a", 10*time.Millisecond)
+	assert.Nil(err)
+	assert.False(success)
+
+	time.Sleep(20 * time.Millisecond)
+	success, err = store.SetNX("a", 10*time.Millisecond)
+	assert.Nil(err)
+	assert.True(success)
+}
+
+func TestGetCanonicalRequest(t *testing.T) {
+	assert := assert.New(t)
+	req := httptest.NewRequest("post", "/users/me?type=1&account=tree.xie", nil)
+	str := GetCanonicalRequest(req.Method, req.URL.Path, req.URL.Query(), []byte("abc"), "1609459200", "nonce")
+	assert.Equal("POST\n/users/me\naccount=tree.xie&type=1\nba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad\n1609459200\nnonce", str)
+	assert.Equal("bc5676dc9b52e08a8543f8d1d78978a937c6372ca81af1c7aec8f861b9fa16a1", SignCanonicalRequest("secret", str))
+}
+
+func TestSignatureAuthNoLookupPanic(t *testing.T) {
+	assert := assert.New(t)
+
+	defer func() {
+		r := recover()
+		assert.Equal(ErrSignatureRequireLookupFunction, r.(error))
+	}()
+	NewSignatureAuth(SignatureAuthConfig{})
+}
+
+func TestSignatureAuthValidate(t *testing.T) {
+	assert := assert.New(t)
+	skipErr := errors.New("skip error")
+	body := []byte(`{"name":"tree.xie"}`)
+
+	fn := NewSignatureAuth(SignatureAuthConfig{
+		Lookup: func(accessKey string, c *elton.Context) (string, error) {
+			if accessKey == "key" {
+				return "secret", nil
+			}
+			return "", nil
+		},
+	})
+	newContext := func(sign func(req *http.Request)) *elton.Context {
+		req := httptest.NewRequest("POST", "/users?type=1", bytes.NewReader(body))
+		sign(req)
+		c := elton.NewContext(httptest.NewRecorder(), req)
+		c.Next = func() error {
+			return skipErr
+		}
+		return c
+	}
+	signWithTimestamp := func(req *http.Request, secret string, timestamp time.Time) {
+		_ = SignHTTPRequest(req, "key", secret, body)
+		ts := strconv.FormatInt(timestamp.Unix(), 10)
+		req.Header.Set(DefaultSignatureTimestampHeader, ts)
+		canonicalRequest := GetCanonicalRequest(req.Method, req.URL.Path, req.URL.Query(), body, ts, req.Header.Get(DefaultSignatureNonceHeader))
+		req.Header.Set(DefaultSignatureHeader, SignCanonicalRequest(secret, canonicalRequest))
+	}
+
+	// 无签名
+	c := newContext(func(_ *http.Request) {})
+	assert.Equal(ErrSignatureMissing, fn(c))
+
+	// 时间戳已过期
+	c = newContext(func(req *http.Request) {
+		signWithTimestamp(req, "secret", time.Now().Add(-10*time.Minute))
+	})
+	assert.Equal(ErrSignatureExpired, fn(c))
+
+	// 签名错误
+	c = newContext(func(req *http.Request) {
+		signWithTimestamp(req, "invalid", time.Now())
+	})
+	assert.Equal(ErrSignatureInvalid, fn(c))
+
+	// 签名成功，并且body可以再次读取
+	var signedReq *http.Request
+	c = newContext(func(req *http.Request) {
+		signWithTimestamp(req, "secret", time.Now())
+		signedReq = req.Clone(req.Context())
+	})
+	assert.Equal(skipErr, fn(c))
+	assert.Equal("key", c.GetString(DefaultAuthIdentityKey))
+	buf, _ := ioutil.ReadAll(c.Request.Body)
+	assert.Equal(body, buf)
+
+	// 重放请求
+	signedReq.Body = ioutil.NopCloser(bytes.NewReader(body))
+	c = elton.NewContext(httptest.NewRecorder(), signedReq)
+	assert.Equal(ErrSignatureReplay, fn(c))
+}
@@ -0,0 +1,180 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vicanso/elton"
+)
+
+type (
+	// SingleflightConfig singleflight config
+	SingleflightConfig struct {
+		// Keys the keys of request, the format is the same as concurrent limiter,
+		// ":ip" real ip, "h:key" request header, "q:key" query, "p:key" params and "key" request body.
+		// The raw query will be used if keys is empty.
+		Keys []string
+		// MaxWait the max wait duration, the request will be handled by itself after timeout,
+		// it's no limit if max wait is 0
+		MaxWait time.Duration
+		// Methods the methods of request will be coalesced, default is GET and HEAD
+		Methods []string
+		// CoalesceCredentials coalesces the requests with Authorization or Cookie,
+		// they are handled by themselves by default. The keys should include the credentials
+		// of user if it's true, otherwise the response may be shared to other users.
+		CoalesceCredentials bool
+		Skipper             elton.Skipper
+	}
+	singleflightCall struct {
+		done chan struct{}
+		resp *CachedResponse
+		err  error
+	}
+	singleflightGroup struct {
+		mutex sync.Mutex
+		calls map[string]*singleflightCall
+	}
+)
+
+// getSingleflightKey returns the key of request, it's method + route + params + keys
+func getSingleflightKey(c *elton.Context, keys []*concurrentLimiterKeyInfo) string {
+	sb := new(strings.Builder)
+	sb.WriteString(c.Request.Method)
+	sb.WriteString(" ")
+	sb.WriteString(c.Route)
+	names := make([]string, 0)
+	if c.Params != nil {
+		names = append(names, c.Params.Keys...)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sb.WriteString(" ")
+		sb.WriteString(name)
+		sb.WriteString("=")
+		sb.WriteString(c.Param(name))
+	}
+	sb.WriteString("?")
+	if len(keys) == 0 {
+		sb.WriteString(c.Request.URL.RawQuery)
+	} else {
+		// 不限制为空，因此不会出错
+		key, _ := getLimiterKey(c, keys, false)
+		sb.WriteString(key)
+	}
+	return sb.String()
+}
+
+// get gets the call of key, the first caller is the leader
+func (g *singleflightGroup) get(key string) (call *singleflightCall, leader bool) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	call, ok := g.calls[key]
+	if ok {
+		return call, false
+	}
+	call = &singleflightCall{
+		done: make(chan struct{}),
+	}
+	g.calls[key] = call
+	return call, true
+}
+
+func (g *singleflightGroup) done(key string, call *singleflightCall) {
+	g.mutex.Lock()
+	delete(g.calls, key)
+	g.mutex.Unlock()
+	close(call.done)
+}
+
+// NewSingleflight returns a new singleflight middleware, the concurrent identical requests
+// will be collapsed into one handler execution, and the status, header and body of response
+// will be shared to all waiters. The request will be handled by itself if the response
+// is a reader body, has Set-Cookie or the waiting is timeout.
+func NewSingleflight(config SingleflightConfig) elton.Handler {
+	keys := parseLimiterKeys(config.Keys)
+	methods := config.Methods
+	if len(methods) == 0 {
+		methods = []string{
+			http.MethodGet,
+			http.MethodHead,
+		}
+	}
+	skipper := config.Skipper
+	if skipper == nil {
+		skipper = elton.DefaultSkipper
+	}
+	group := &singleflightGroup{
+		calls: make(map[string]*singleflightCall),
+	}
+	return func(c *elton.Context) (err error) {
+		if skipper(c) || !containsString(methods, c.Request.Method) {
+			return c.Next()
+		}
+		// 有认证信息的请求响应数据可能与用户相关，不合并
+		if !config.CoalesceCredentials &&
+			(c.GetRequestHeader(elton.HeaderAuthorization) != "" ||
+				c.GetRequestHeader(elton.HeaderCookie) != "") {
+			return c.Next()
+		}
+		key := getSingleflightKey(c, keys)
+		call, leader := group.get(key)
+		if leader {
+			// 如果panic也需要删除，waiter则自行处理
+			defer group.done(key, call)
+			err = c.Next()
+			// 设置了cookie的响应不共享，waiter则自行处理
+			if !c.IsReaderBody() && c.GetHeader(elton.HeaderSetCookie) == "" {
+				call.err = err
+				if err == nil {
+					call.resp = newCachedResponse(c)
+				}
+			}
+			return
+		}
+
+		if config.MaxWait > 0 {
+			timer := time.NewTimer(config.MaxWait)
+			defer timer.Stop()
+			select {
+			case <-call.done:
+			case <-timer.C:
+				return c.Next()
+			}
+		} else {
+			<-call.done
+		}
+		if call.err != nil {
+			return call.err
+		}
+		if call.resp == nil {
+			return c.Next()
+		}
+		call.resp.applyTo(c)
+		return nil
+	}
+}
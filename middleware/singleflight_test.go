@@ -0,0 +1,234 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vicanso/elton"
+)
+
+func TestGetSingleflightKey(t *testing.T) {
+	assert := assert.New(t)
+
+	req := httptest.NewRequest("GET", "/users/1?type=vip&page=1", nil)
+	req.Header.Set("X-Tenant", "elton")
+	c := elton.NewContext(httptest.NewRecorder(), req)
+	c.Route = "/users/{id}"
+	c.Params = new(elton.RouteParams)
+	c.Params.Add("id", "1")
+
+	assert.Equal("GET /users/{id} id=1?type=vip&page=1", getSingleflightKey(c, nil))
+	assert.Equal("GET /users/{id} id=1?vip,elton", getSingleflightKey(c, parseLimiterKeys([]string{
+		"q:type",
+		"h:X-Tenant",
+	})))
+}
+
+func TestSingleflight(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("coalesce", func(t *testing.T) {
+		var calls int32
+		release := make(chan struct{})
+		e := elton.New()
+		e.Use(NewSingleflight(SingleflightConfig{}))
+		e.GET("/users/{id}", func(c *elton.Context) error {
+			atomic.AddInt32(&calls, 1)
+			<-release
+			c.SetHeader("X-Response-Id", c.Param("id"))
+			c.BodyBuffer = bytes.NewBufferString("user " + c.Param("id"))
+			return nil
+		})
+		count := 5
+		wg := sync.WaitGroup{}
+		resps := make([]*httptest.ResponseRecorder, count)
+		for i := 0; i < count; i++ {
+			wg.Add(1)
+			resp := httptest.NewRecorder()
+			resps[i] = resp
+			go func() {
+				defer wg.Done()
+				e.ServeHTTP(resp, httptest.NewRequest("GET", "/users/1", nil))
+			}()
+		}
+		// 等待所有请求均已进入
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		assert.Equal(int32(1), atomic.LoadInt32(&calls))
+		for _, resp := range resps {
+			assert.Equal(200, resp.Code)
+			assert.Equal("1", resp.Header().Get("X-Response-Id"))
+			assert.Equal("user 1", resp.Body.String())
+		}
+	})
+
+	t.Run("credentials", func(t *testing.T) {
+		var calls int32
+		release := make(chan struct{})
+		e := elton.New()
+		e.Use(NewSingleflight(SingleflightConfig{}))
+		e.GET("/me", func(c *elton.Context) error {
+			atomic.AddInt32(&calls, 1)
+			<-release
+			user := c.GetRequestHeader(elton.HeaderAuthorization)
+			c.SetHeader(elton.HeaderSetCookie, "sid="+user)
+			c.BodyBuffer = bytes.NewBufferString("user:" + user)
+			return nil
+		})
+		users := []string{"alice", "bob"}
+		wg := sync.WaitGroup{}
+		resps := make([]*httptest.ResponseRecorder, len(users))
+		for i, user := range users {
+			wg.Add(1)
+			resp := httptest.NewRecorder()
+			resps[i] = resp
+			req := httptest.NewRequest("GET", "/me", nil)
+			req.Header.Set(elton.HeaderAuthorization, user)
+			go func() {
+				defer wg.Done()
+				e.ServeHTTP(resp, req)
+			}()
+		}
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		assert.Equal(int32(2), atomic.LoadInt32(&calls))
+		for i, user := range users {
+			assert.Equal("user:"+user, resps[i].Body.String())
+			assert.Equal("sid="+user, resps[i].Header().Get(elton.HeaderSetCookie))
+		}
+	})
+
+	t.Run("not share set-cookie", func(t *testing.T) {
+		var calls int32
+		release := make(chan struct{})
+		e := elton.New()
+		e.Use(NewSingleflight(SingleflightConfig{}))
+		e.GET("/", func(c *elton.Context) error {
+			count := atomic.AddInt32(&calls, 1)
+			if count == 1 {
+				<-release
+				c.SetHeader(elton.HeaderSetCookie, "sid=1")
+			}
+			c.BodyBuffer = bytes.NewBufferString("ok")
+			return nil
+		})
+		leaderResp := httptest.NewRecorder()
+		done := make(chan bool)
+		go func() {
+			e.ServeHTTP(leaderResp, httptest.NewRequest("GET", "/", nil))
+			done <- true
+		}()
+		time.Sleep(10 * time.Millisecond)
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			close(release)
+		}()
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, httptest.NewRequest("GET", "/", nil))
+		<-done
+		assert.Equal(int32(2), atomic.LoadInt32(&calls))
+		assert.Equal("sid=1", leaderResp.Header().Get(elton.HeaderSetCookie))
+		assert.Empty(resp.Header().Get(elton.HeaderSetCookie))
+		assert.Equal("ok", resp.Body.String())
+	})
+
+	t.Run("share error", func(t *testing.T) {
+		customErr := errors.New("abc")
+		fn := NewSingleflight(SingleflightConfig{})
+		c := elton.NewContext(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		c.Next = func() error {
+			return customErr
+		}
+		assert.Equal(customErr, fn(c))
+		// 执行完成后删除
+		c.Next = func() error {
+			return nil
+		}
+		assert.Nil(fn(c))
+	})
+
+	t.Run("bypass methods", func(t *testing.T) {
+		var calls int32
+		release := make(chan struct{})
+		fn := NewSingleflight(SingleflightConfig{})
+		wg := sync.WaitGroup{}
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c := elton.NewContext(httptest.NewRecorder(), httptest.NewRequest("POST", "/", nil))
+				c.Next = func() error {
+					atomic.AddInt32(&calls, 1)
+					<-release
+					return nil
+				}
+				_ = fn(c)
+			}()
+		}
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		assert.Equal(int32(2), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("max wait", func(t *testing.T) {
+		var calls int32
+		release := make(chan struct{})
+		fn := NewSingleflight(SingleflightConfig{
+			MaxWait: 10 * time.Millisecond,
+		})
+		done := make(chan bool)
+		go func() {
+			c := elton.NewContext(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+			c.Next = func() error {
+				atomic.AddInt32(&calls, 1)
+				<-release
+				return nil
+			}
+			_ = fn(c)
+			done <- true
+		}()
+		time.Sleep(5 * time.Millisecond)
+		c := elton.NewContext(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		c.Next = func() error {
+			atomic.AddInt32(&calls, 1)
+			c.BodyBuffer = bytes.NewBufferString("self")
+			return nil
+		}
+		assert.Nil(fn(c))
+		assert.Equal("self", c.BodyBuffer.String())
+		close(release)
+		<-done
+		assert.Equal(int32(2), atomic.LoadInt32(&calls))
+	})
+}
@@ -0,0 +1,283 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"bytes"
+	"container/list"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultStaticCacheMaxSize default max size of static cache(64MB)
+	DefaultStaticCacheMaxSize = 64 * 1024 * 1024
+	// DefaultStaticCacheMaxFileSize default max size of file for static cache(1MB)
+	DefaultStaticCacheMaxFileSize = 1024 * 1024
+)
+
+type (
+	// StaticFileETag the static file supports etag, it's used for strong etag to avoid hashing file every request
+	StaticFileETag interface {
+		// ETag returns the strong etag of file, returns empty string if it's not supported
+		ETag(string) string
+	}
+	// CachedStaticFileConfig cached static file config
+	CachedStaticFileConfig struct {
+		// MaxSize the max size of all cached files, default is 64MB
+		MaxSize int64
+		// MaxFileSize the max size of cached file, the file is not cached if it's over the limit, default is 1MB
+		MaxFileSize int64
+		// CheckInterval the interval to check whether the file is changed by stat(size and modified time),
+		// it's checked every access if it's 0, and never checked if it's negative
+		CheckInterval time.Duration
+	}
+	cachedStaticFileEntry struct {
+		file      string
+		data      []byte
+		eTag      string
+		size      int64
+		modTime   time.Time
+		checkedAt time.Time
+	}
+	// CachedStaticFile the static file decorator, it caches the hot files in lru with memory limit,
+	// and precomputes the strong etag
+	CachedStaticFile struct {
+		staticFile    StaticFile
+		mutex         sync.Mutex
+		maxSize       int64
+		maxFileSize   int64
+		checkInterval time.Duration
+		size          int64
+		ll            *list.List
+		m             map[string]*list.Element
+		now           func() time.Time
+	}
+)
+
+var errStaticDirNotSupported = errors.New("read dir is not supported")
+
+// NewCachedStaticFile returns a new cached static file
+func NewCachedStaticFile(staticFile StaticFile, config CachedStaticFileConfig) *CachedStaticFile {
+	maxSize := config.MaxSize
+	if maxSize <= 0 {
+		maxSize = DefaultStaticCacheMaxSize
+	}
+	maxFileSize := config.MaxFileSize
+	if maxFileSize <= 0 {
+		maxFileSize = DefaultStaticCacheMaxFileSize
+	}
+	return &CachedStaticFile{
+		staticFile:    staticFile,
+		maxSize:       maxSize,
+		maxFileSize:   maxFileSize,
+		checkInterval: config.CheckInterval,
+		ll:            list.New(),
+		m:             make(map[string]*list.Element),
+		now:           time.Now,
+	}
+}
+
+// isChanged checks whether the file is changed by stat
+func (cs *CachedStaticFile) isChanged(entry *cachedStaticFileEntry) bool {
+	if cs.checkInterval < 0 {
+		return false
+	}
+	now := cs.now()
+	if cs.checkInterval > 0 && now.Sub(entry.checkedAt) < cs.checkInterval {
+		return false
+	}
+	entry.checkedAt = now
+	info := cs.staticFile.Stat(entry.file)
+	// 无stat的则认为不会变化(如embed)
+	if info == nil {
+		return false
+	}
+	return info.Size() != entry.size || !info.ModTime().Equal(entry.modTime)
+}
+
+// isOversized checks whether the file is over the limit of cache by stat
+func (cs *CachedStaticFile) isOversized(file string) bool {
+	info := cs.staticFile.Stat(file)
+	if info == nil {
+		return false
+	}
+	size := info.Size()
+	return size > cs.maxFileSize || size > cs.maxSize
+}
+
+// remove removes the element, the lock should be held
+func (cs *CachedStaticFile) remove(e *list.Element) {
+	entry := e.Value.(*cachedStaticFileEntry)
+	cs.ll.Remove(e)
+	delete(cs.m, entry.file)
+	cs.size -= int64(len(entry.data))
+}
+
+// get returns the cached entry, it will be loaded if it's not cached or changed
+func (cs *CachedStaticFile) get(file string) (*cachedStaticFileEntry, error) {
+	cs.mutex.Lock()
+	if e, ok := cs.m[file]; ok {
+		entry := e.Value.(*cachedStaticFileEntry)
+		if !cs.isChanged(entry) {
+			cs.ll.MoveToFront(e)
+			cs.mutex.Unlock()
+			return entry, nil
+		}
+		cs.remove(e)
+	}
+	cs.mutex.Unlock()
+
+	// 读取文件时不锁定
+	data, err := cs.staticFile.Get(file)
+	if err != nil {
+		return nil, err
+	}
+	entry := &cachedStaticFileEntry{
+		file:      file,
+		data:      data,
+		size:      int64(len(data)),
+		checkedAt: cs.now(),
+	}
+	info := cs.staticFile.Stat(file)
+	if info != nil {
+		entry.size = info.Size()
+		entry.modTime = info.ModTime()
+	}
+	dataSize := int64(len(data))
+	// 超出限制的文件不缓存，也不生成etag
+	if dataSize > cs.maxFileSize || dataSize > cs.maxSize {
+		return entry, nil
+	}
+	entry.eTag = generateETag(data)
+
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	// 有可能其它请求已加载
+	if e, ok := cs.m[file]; ok {
+		cs.remove(e)
+	}
+	cs.m[file] = cs.ll.PushFront(entry)
+	cs.size += dataSize
+	for cs.size > cs.maxSize {
+		cs.remove(cs.ll.Back())
+	}
+	return entry, nil
+}
+
+// Exists check the file exists
+func (cs *CachedStaticFile) Exists(file string) bool {
+	cs.mutex.Lock()
+	_, ok := cs.m[file]
+	cs.mutex.Unlock()
+	if ok {
+		return true
+	}
+	return cs.staticFile.Exists(file)
+}
+
+// Get returns content of file from cache,
+// the file over the limit of cache is read from static file directly.
+func (cs *CachedStaticFile) Get(file string) ([]byte, error) {
+	if cs.isOversized(file) {
+		return cs.staticFile.Get(file)
+	}
+	entry, err := cs.get(file)
+	if err != nil {
+		return nil, err
+	}
+	return entry.data, nil
+}
+
+// Stat returns the file info of file
+func (cs *CachedStaticFile) Stat(file string) os.FileInfo {
+	return cs.staticFile.Stat(file)
+}
+
+// NewReader returns a reader of file from cache,
+// the file over the limit of cache is streamed from static file.
+func (cs *CachedStaticFile) NewReader(file string) (io.Reader, error) {
+	if cs.isOversized(file) {
+		return cs.staticFile.NewReader(file)
+	}
+	buf, err := cs.Get(file)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(buf), nil
+}
+
+// ETag returns the precomputed strong etag of file,
+// it returns empty string for the file over the limit of cache.
+func (cs *CachedStaticFile) ETag(file string) string {
+	if cs.isOversized(file) {
+		return ""
+	}
+	entry, err := cs.get(file)
+	if err != nil {
+		return ""
+	}
+	return entry.eTag
+}
+
+// IsDir checks the file is directory
+func (cs *CachedStaticFile) IsDir(file string) bool {
+	return isStaticDir(cs.staticFile, file)
+}
+
+// ReadDir returns the file info list of directory
+func (cs *CachedStaticFile) ReadDir(file string) ([]os.FileInfo, error) {
+	dirFile, ok := cs.staticFile.(StaticDirFile)
+	if !ok {
+		return nil, errStaticDirNotSupported
+	}
+	return dirFile.ReadDir(file)
+}
+
+// Invalidate removes the file from cache
+func (cs *CachedStaticFile) Invalidate(file string) {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	if e, ok := cs.m[file]; ok {
+		cs.remove(e)
+	}
+}
+
+// Purge removes all files from cache
+func (cs *CachedStaticFile) Purge() {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	cs.ll.Init()
+	cs.m = make(map[string]*list.Element)
+	cs.size = 0
+}
+
+// Len returns the count and size of cached files
+func (cs *CachedStaticFile) Len() (int, int64) {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	return cs.ll.Len(), cs.size
+}
@@ -0,0 +1,221 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vicanso/elton"
+)
+
+type countStaticFile struct {
+	files   map[string]string
+	modTime time.Time
+	gets    int
+	readers int
+}
+
+type countFileStat struct {
+	MockFileStat
+	size    int64
+	modTime time.Time
+}
+
+func (info *countFileStat) Size() int64 {
+	return info.size
+}
+
+func (info *countFileStat) ModTime() time.Time {
+	return info.modTime
+}
+
+func (cf *countStaticFile) Exists(file string) bool {
+	_, ok := cf.files[file]
+	return ok
+}
+
+func (cf *countStaticFile) Get(file string) ([]byte, error) {
+	cf.gets++
+	data, ok := cf.files[file]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return []byte(data), nil
+}
+
+func (cf *countStaticFile) Stat(file string) os.FileInfo {
+	data, ok := cf.files[file]
+	if !ok {
+		return nil
+	}
+	return &countFileStat{
+		size:    int64(len(data)),
+		modTime: cf.modTime,
+	}
+}
+
+func (cf *countStaticFile) NewReader(file string) (io.Reader, error) {
+	cf.readers++
+	data, ok := cf.files[file]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return strings.NewReader(data), nil
+}
+
+func TestCachedStaticFile(t *testing.T) {
+	assert := assert.New(t)
+	clock := newFakeClock()
+	sf := &countStaticFile{
+		files: map[string]string{
+			"/a.txt":   "aaaa",
+			"/b.txt":   "bbbb",
+			"/c.txt":   "cccc",
+			"/big.txt": "0123456789",
+		},
+		modTime: clock.Now(),
+	}
+	cs := NewCachedStaticFile(sf, CachedStaticFileConfig{
+		MaxSize:       8,
+		MaxFileSize:   6,
+		CheckInterval: time.Second,
+	})
+	cs.now = clock.Now
+
+	for i := 0; i < 3; i++ {
+		buf, err := cs.Get("/a.txt")
+		assert.Nil(err)
+		assert.Equal("aaaa", string(buf))
+		assert.Equal(generateETag([]byte("aaaa")), cs.ETag("/a.txt"))
+	}
+	assert.Equal(1, sf.gets)
+
+	// 超过单个文件限制的不缓存
+	_, _ = cs.Get("/big.txt")
+	_, _ = cs.Get("/big.txt")
+	assert.Equal(3, sf.gets)
+	// 超过限制的文件直接读取，也不生成etag
+	r, err := cs.NewReader("/big.txt")
+	assert.Nil(err)
+	buf, _ := io.ReadAll(r)
+	assert.Equal("0123456789", string(buf))
+	assert.Equal(1, sf.readers)
+	assert.Empty(cs.ETag("/big.txt"))
+	assert.Equal(3, sf.gets)
+	count, _ := cs.Len()
+	assert.Equal(1, count)
+
+	// 超过总大小，淘汰最少使用的a
+	_, _ = cs.Get("/b.txt")
+	_, _ = cs.Get("/c.txt")
+	count, size := cs.Len()
+	assert.Equal(2, count)
+	assert.Equal(int64(8), size)
+	sf.gets = 0
+	_, _ = cs.Get("/a.txt")
+	assert.Equal(1, sf.gets)
+
+	// 文件修改后，在检测周期后重新加载
+	sf.files["/a.txt"] = "new a"
+	buf, _ = cs.Get("/a.txt")
+	assert.Equal("aaaa", string(buf))
+	clock.Add(2 * time.Second)
+	buf, _ = cs.Get("/a.txt")
+	assert.Equal("new a", string(buf))
+	assert.Equal(generateETag([]byte("new a")), cs.ETag("/a.txt"))
+
+	sf.gets = 0
+	cs.Invalidate("/a.txt")
+	_, _ = cs.Get("/a.txt")
+	assert.Equal(1, sf.gets)
+
+	cs.Purge()
+	count, size = cs.Len()
+	assert.Equal(0, count)
+	assert.Equal(int64(0), size)
+}
+
+func TestCachedStaticFileStaticServe(t *testing.T) {
+	assert := assert.New(t)
+	sf := &countStaticFile{
+		files: map[string]string{
+			"/index.html": "<html></html>",
+		},
+	}
+	fn := NewStaticServe(NewCachedStaticFile(sf, CachedStaticFileConfig{}), StaticServeConfig{
+		EnableStrongETag: true,
+	})
+	for i := 0; i < 3; i++ {
+		c := elton.NewContext(httptest.NewRecorder(), httptest.NewRequest("GET", "/index.html", nil))
+		c.Next = func() error {
+			return nil
+		}
+		assert.Nil(fn(c))
+		assert.Equal(generateETag([]byte("<html></html>")), c.GetHeader(elton.HeaderETag))
+		assert.Equal("<html></html>", c.BodyBuffer.String())
+	}
+	assert.Equal(1, sf.gets)
+}
+
+func TestCachedStaticFileStaticServeOversized(t *testing.T) {
+	assert := assert.New(t)
+	sf := &countStaticFile{
+		files: map[string]string{
+			"/big.txt": "0123456789",
+		},
+	}
+	cs := NewCachedStaticFile(sf, CachedStaticFileConfig{
+		MaxFileSize: 6,
+	})
+
+	// weak etag则通过reader读取
+	fn := NewStaticServe(cs, StaticServeConfig{})
+	c := elton.NewContext(httptest.NewRecorder(), httptest.NewRequest("GET", "/big.txt", nil))
+	c.Next = func() error {
+		return nil
+	}
+	assert.Nil(fn(c))
+	assert.True(c.IsReaderBody())
+	assert.Equal(0, sf.gets)
+	assert.Equal(1, sf.readers)
+
+	// strong etag则读取文件后生成
+	fn = NewStaticServe(cs, StaticServeConfig{
+		EnableStrongETag: true,
+	})
+	c = elton.NewContext(httptest.NewRecorder(), httptest.NewRequest("GET", "/big.txt", nil))
+	c.Next = func() error {
+		return nil
+	}
+	assert.Nil(fn(c))
+	assert.Equal(generateETag([]byte("0123456789")), c.GetHeader(elton.HeaderETag))
+	assert.Equal("0123456789", c.BodyBuffer.String())
+	assert.Equal(1, sf.gets)
+}
@@ -0,0 +1,318 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	gopath "path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type (
+	// StaticFS the static file of io/fs.FS, such as os.DirFS, embed.FS or zip.Reader
+	StaticFS struct {
+		// Prefix prefix of file
+		Prefix string
+		FS     fs.FS
+		closer io.Closer
+	}
+	// tarStaticFile the static file of tar archive, all files are loaded in memory
+	tarStaticFile struct {
+		files map[string]*tarFile
+		dirs  map[string][]os.FileInfo
+	}
+	tarFile struct {
+		data []byte
+		info os.FileInfo
+	}
+	// tarDirInfo the file info of directory which is not in tar archive
+	tarDirInfo struct {
+		name string
+	}
+)
+
+// NewStaticFS returns a new static file of io/fs.FS
+func NewStaticFS(fsys fs.FS, prefix string) *StaticFS {
+	return &StaticFS{
+		Prefix: prefix,
+		FS:     fsys,
+	}
+}
+
+// NewZipStaticFile returns a new static file of zip archive, it should be closed if it's no longer used
+func NewZipStaticFile(file string) (*StaticFS, error) {
+	r, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, err
+	}
+	sf := NewStaticFS(r, "")
+	sf.closer = r
+	return sf, nil
+}
+
+// getFile converts the file to the valid path of io/fs.FS(unrooted and slash-separated)
+func (sf *StaticFS) getFile(file string) string {
+	file = gopath.Join(sf.Prefix, filepath.ToSlash(file))
+	file = strings.TrimPrefix(gopath.Clean("/"+file), "/")
+	if file == "" {
+		return "."
+	}
+	return file
+}
+
+// Exists check the file exists
+func (sf *StaticFS) Exists(file string) bool {
+	_, err := fs.Stat(sf.FS, sf.getFile(file))
+	return err == nil
+}
+
+// Get returns content of file
+func (sf *StaticFS) Get(file string) ([]byte, error) {
+	return fs.ReadFile(sf.FS, sf.getFile(file))
+}
+
+// Stat returns the file info of file
+func (sf *StaticFS) Stat(file string) os.FileInfo {
+	info, _ := fs.Stat(sf.FS, sf.getFile(file))
+	return info
+}
+
+// NewReader returns a reader of file
+func (sf *StaticFS) NewReader(file string) (io.Reader, error) {
+	return sf.FS.Open(sf.getFile(file))
+}
+
+// IsDir checks the file is directory
+func (sf *StaticFS) IsDir(file string) bool {
+	info := sf.Stat(file)
+	return info != nil && info.IsDir()
+}
+
+// ReadDir returns the file info list of directory
+func (sf *StaticFS) ReadDir(file string) ([]os.FileInfo, error) {
+	entries, err := fs.ReadDir(sf.FS, sf.getFile(file))
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// Close closes the archive file
+func (sf *StaticFS) Close() error {
+	if sf.closer == nil {
+		return nil
+	}
+	return sf.closer.Close()
+}
+
+func (info *tarDirInfo) Name() string {
+	return info.name
+}
+
+func (info *tarDirInfo) Size() int64 {
+	return 0
+}
+
+func (info *tarDirInfo) Mode() os.FileMode {
+	return os.ModeDir | 0555
+}
+
+func (info *tarDirInfo) ModTime() time.Time {
+	return time.Time{}
+}
+
+func (info *tarDirInfo) IsDir() bool {
+	return true
+}
+
+func (info *tarDirInfo) Sys() interface{} {
+	return nil
+}
+
+// NewTarStaticFile returns a new static file of tar archive(.tar or .tar.gz), all files are loaded in memory
+func NewTarStaticFile(file string) (StaticFile, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return NewTarStaticFileFromReader(f)
+}
+
+// NewTarStaticFileFromReader returns a new static file of tar archive reader, the gzip data is supported
+func NewTarStaticFileFromReader(r io.Reader) (StaticFile, error) {
+	br := bufio.NewReader(r)
+	// 根据gzip的magic number判断是否gzip压缩
+	header, _ := br.Peek(2)
+	var reader io.Reader = br
+	if len(header) == 2 && header[0] == 0x1f && header[1] == 0x8b {
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		reader = gr
+	}
+	sf := &tarStaticFile{
+		files: make(map[string]*tarFile),
+		dirs: map[string][]os.FileInfo{
+			".": make([]os.FileInfo, 0),
+		},
+	}
+	tr := tar.NewReader(reader)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := sf.getFile(h.Name)
+		if name == "." {
+			continue
+		}
+		info := h.FileInfo()
+		switch h.Typeflag {
+		case tar.TypeDir:
+			sf.addDir(name, info)
+		case tar.TypeReg:
+			data, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			sf.files[name] = &tarFile{
+				data: data,
+				info: info,
+			}
+			sf.addDir(gopath.Dir(name), nil)
+			sf.dirs[gopath.Dir(name)] = append(sf.dirs[gopath.Dir(name)], info)
+		}
+	}
+	for _, infos := range sf.dirs {
+		sort.Slice(infos, func(i, j int) bool {
+			return infos[i].Name() < infos[j].Name()
+		})
+	}
+	return sf, nil
+}
+
+// addDir adds the directory and its parent directories
+func (sf *tarStaticFile) addDir(dir string, info os.FileInfo) {
+	if _, ok := sf.dirs[dir]; ok || dir == "." {
+		return
+	}
+	sf.dirs[dir] = make([]os.FileInfo, 0)
+	if info == nil {
+		info = &tarDirInfo{
+			name: gopath.Base(dir),
+		}
+	}
+	parent := gopath.Dir(dir)
+	sf.addDir(parent, nil)
+	sf.dirs[parent] = append(sf.dirs[parent], info)
+}
+
+func (sf *tarStaticFile) getFile(file string) string {
+	file = strings.TrimPrefix(gopath.Clean("/"+filepath.ToSlash(file)), "/")
+	if file == "" {
+		return "."
+	}
+	return file
+}
+
+// Exists check the file exists
+func (sf *tarStaticFile) Exists(file string) bool {
+	name := sf.getFile(file)
+	if _, ok := sf.files[name]; ok {
+		return true
+	}
+	_, ok := sf.dirs[name]
+	return ok
+}
+
+// Get returns content of file
+func (sf *tarStaticFile) Get(file string) ([]byte, error) {
+	f, ok := sf.files[sf.getFile(file)]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return f.data, nil
+}
+
+// Stat returns the file info of file
+func (sf *tarStaticFile) Stat(file string) os.FileInfo {
+	name := sf.getFile(file)
+	if f, ok := sf.files[name]; ok {
+		return f.info
+	}
+	if _, ok := sf.dirs[name]; ok {
+		return &tarDirInfo{
+			name: gopath.Base(name),
+		}
+	}
+	return nil
+}
+
+// NewReader returns a reader of file
+func (sf *tarStaticFile) NewReader(file string) (io.Reader, error) {
+	buf, err := sf.Get(file)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(buf), nil
+}
+
+// IsDir checks the file is directory
+func (sf *tarStaticFile) IsDir(file string) bool {
+	_, ok := sf.dirs[sf.getFile(file)]
+	return ok
+}
+
+// ReadDir returns the file info list of directory
+func (sf *tarStaticFile) ReadDir(file string) ([]os.FileInfo, error) {
+	infos, ok := sf.dirs[sf.getFile(file)]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return infos, nil
+}
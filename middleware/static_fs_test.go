@@ -0,0 +1,161 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vicanso/elton"
+)
+
+var staticArchiveFiles = map[string]string{
+	"index.html":    "<html>index</html>",
+	"static/app.js": "var a = 1;",
+}
+
+func testStaticFileArchive(t *testing.T, sf StaticFile) {
+	assert := assert.New(t)
+	assert.True(sf.Exists("/index.html"))
+	assert.True(sf.Exists("static/app.js"))
+	assert.True(sf.Exists("/static"))
+	assert.False(sf.Exists("/notfound.html"))
+
+	buf, err := sf.Get("/static/app.js")
+	assert.Nil(err)
+	assert.Equal("var a = 1;", string(buf))
+
+	info := sf.Stat("/index.html")
+	assert.Equal(int64(len("<html>index</html>")), info.Size())
+
+	r, err := sf.NewReader("/index.html")
+	assert.Nil(err)
+	buf, _ = ioutil.ReadAll(r)
+	assert.Equal("<html>index</html>", string(buf))
+
+	dirFile := sf.(StaticDirFile)
+	assert.True(dirFile.IsDir("/static"))
+	assert.False(dirFile.IsDir("/index.html"))
+	infos, err := dirFile.ReadDir("/")
+	assert.Nil(err)
+	names := make([]string, 0)
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	assert.Equal([]string{"index.html", "static"}, names)
+
+	// 通过static serve访问
+	fn := NewStaticServe(sf, StaticServeConfig{
+		Index: "index.html",
+	})
+	c := elton.NewContext(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	c.Next = func() error {
+		return nil
+	}
+	assert.Nil(fn(c))
+	buf, _ = ioutil.ReadAll(c.Body.(io.Reader))
+	assert.Equal("<html>index</html>", string(buf))
+}
+
+func TestStaticFS(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	for name, data := range staticArchiveFiles {
+		file := filepath.Join(dir, name)
+		assert.Nil(os.MkdirAll(filepath.Dir(file), 0700))
+		assert.Nil(ioutil.WriteFile(file, []byte(data), 0600))
+	}
+	sf := NewStaticFS(os.DirFS(dir), "")
+	assert.Equal("static/app.js", sf.getFile("/static/app.js"))
+	assert.Equal("static/app.js", sf.getFile("../static/app.js"))
+	assert.Equal(".", sf.getFile("/"))
+	assert.Nil(sf.Close())
+	testStaticFileArchive(t, sf)
+}
+
+func TestZipStaticFile(t *testing.T) {
+	assert := assert.New(t)
+	file := filepath.Join(t.TempDir(), "static.zip")
+	buffer := new(bytes.Buffer)
+	w := zip.NewWriter(buffer)
+	for name, data := range staticArchiveFiles {
+		fw, err := w.Create(name)
+		assert.Nil(err)
+		_, err = fw.Write([]byte(data))
+		assert.Nil(err)
+	}
+	assert.Nil(w.Close())
+	assert.Nil(ioutil.WriteFile(file, buffer.Bytes(), 0600))
+
+	sf, err := NewZipStaticFile(file)
+	assert.Nil(err)
+	defer sf.Close()
+	testStaticFileArchive(t, sf)
+}
+
+func TestTarStaticFile(t *testing.T) {
+	assert := assert.New(t)
+	newTar := func(compress bool) []byte {
+		buffer := new(bytes.Buffer)
+		var gw *gzip.Writer
+		tw := tar.NewWriter(buffer)
+		if compress {
+			gw = gzip.NewWriter(buffer)
+			tw = tar.NewWriter(gw)
+		}
+		for name, data := range staticArchiveFiles {
+			err := tw.WriteHeader(&tar.Header{
+				Name: name,
+				Mode: 0600,
+				Size: int64(len(data)),
+			})
+			assert.Nil(err)
+			_, err = tw.Write([]byte(data))
+			assert.Nil(err)
+		}
+		assert.Nil(tw.Close())
+		if gw != nil {
+			assert.Nil(gw.Close())
+		}
+		return buffer.Bytes()
+	}
+
+	sf, err := NewTarStaticFileFromReader(bytes.NewReader(newTar(false)))
+	assert.Nil(err)
+	testStaticFileArchive(t, sf)
+
+	file := filepath.Join(t.TempDir(), "static.tar.gz")
+	assert.Nil(ioutil.WriteFile(file, newTar(true), 0600))
+	sf, err = NewTarStaticFile(file)
+	assert.Nil(err)
+	testStaticFileArchive(t, sf)
+}
@@ -0,0 +1,180 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	gopath "path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultAssetHashLength default length of asset hash
+	DefaultAssetHashLength = 8
+	// DefaultAssetMaxAge default max age of fingerprinted asset(one year)
+	DefaultAssetMaxAge = 365 * 24 * time.Hour
+)
+
+var assetCacheControl = "public, max-age=" + toSeconds(DefaultAssetMaxAge) + ", immutable"
+
+type (
+	// AssetManifestConfig asset manifest config
+	AssetManifestConfig struct {
+		// Path the path of static file, it should be the same as the path of static serve
+		Path string
+		// Prefix the url prefix of asset, e.g. /static
+		Prefix string
+		// HashLength the length of content hash, default is 8, max is 64(hex of sha256)
+		HashLength int
+	}
+	// AssetManifest the manifest of fingerprinted assets,
+	// it maps the logical name to the hashed name, e.g. app.js -> app.3f2a1c0d.js
+	AssetManifest struct {
+		mutex      sync.RWMutex
+		staticFile StaticFile
+		path       string
+		prefix     string
+		hashLength int
+		assets     map[string]string
+		// hashed name -> logical name
+		files map[string]string
+	}
+)
+
+// NewAssetManifest returns a new asset manifest, it scans all files of static file(the dot files are ignored)
+// and computes the content hashes. The static file should implement StaticDirFile.
+func NewAssetManifest(staticFile StaticFile, config AssetManifestConfig) (*AssetManifest, error) {
+	hashLength := config.HashLength
+	if hashLength <= 0 {
+		hashLength = DefaultAssetHashLength
+	}
+	// 不能超过sha256的hex长度
+	if hashLength > 2*sha256.Size {
+		hashLength = 2 * sha256.Size
+	}
+	m := &AssetManifest{
+		staticFile: staticFile,
+		path:       config.Path,
+		prefix:     strings.TrimSuffix(config.Prefix, "/"),
+		hashLength: hashLength,
+	}
+	err := m.Reload()
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// getHashedName returns the hashed name of file, e.g. js/app.js -> js/app.3f2a1c0d.js
+func getHashedName(name, hash string) string {
+	ext := gopath.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// walk walks the directory and computes the hash of files
+func (m *AssetManifest) walk(dirFile StaticDirFile, dir string, assets map[string]string) error {
+	infos, err := dirFile.ReadDir(filepath.Join(m.path, dir))
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		name := info.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		name = gopath.Join(dir, name)
+		if info.IsDir() {
+			err = m.walk(dirFile, name, assets)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		buf, err := m.staticFile.Get(filepath.Join(m.path, name))
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(buf)
+		assets[name] = getHashedName(name, hex.EncodeToString(sum[:])[:m.hashLength])
+	}
+	return nil
+}
+
+// Reload rescans the files and recomputes the hashes
+func (m *AssetManifest) Reload() error {
+	dirFile, ok := m.staticFile.(StaticDirFile)
+	if !ok {
+		return errStaticDirNotSupported
+	}
+	assets := make(map[string]string)
+	err := m.walk(dirFile, "", assets)
+	if err != nil {
+		return err
+	}
+	files := make(map[string]string, len(assets))
+	for name, hashedName := range assets {
+		files[hashedName] = name
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.assets = assets
+	m.files = files
+	return nil
+}
+
+// AssetURL returns the url of fingerprinted asset, e.g. app.js -> /static/app.3f2a1c0d.js,
+// it returns the url of original name if the asset is not found
+func (m *AssetManifest) AssetURL(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	m.mutex.RLock()
+	hashedName, ok := m.assets[name]
+	m.mutex.RUnlock()
+	if !ok {
+		hashedName = name
+	}
+	return m.prefix + "/" + hashedName
+}
+
+// Resolve returns the logical name of hashed name, e.g. app.3f2a1c0d.js -> app.js
+func (m *AssetManifest) Resolve(hashedName string) (string, bool) {
+	hashedName = strings.TrimPrefix(filepath.ToSlash(hashedName), "/")
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	name, ok := m.files[hashedName]
+	return name, ok
+}
+
+// Assets returns the copy of manifest, the key is logical name and the value is hashed name
+func (m *AssetManifest) Assets() map[string]string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	assets := make(map[string]string, len(m.assets))
+	for k, v := range m.assets {
+		assets[k] = v
+	}
+	return assets
+}
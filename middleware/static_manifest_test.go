@@ -0,0 +1,114 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vicanso/elton"
+)
+
+func TestGetHashedName(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("js/app.3f2a1c.js", getHashedName("js/app.js", "3f2a1c"))
+	assert.Equal("LICENSE.3f2a1c", getHashedName("LICENSE", "3f2a1c"))
+}
+
+func TestAssetManifest(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	files := map[string]string{
+		"index.html": "<html></html>",
+		"js/app.js":  "var a = 1;",
+		".env":       "secret",
+	}
+	for name, data := range files {
+		file := filepath.Join(dir, name)
+		assert.Nil(os.MkdirAll(filepath.Dir(file), 0700))
+		assert.Nil(ioutil.WriteFile(file, []byte(data), 0600))
+	}
+	sf := new(FS)
+	m, err := NewAssetManifest(sf, AssetManifestConfig{
+		Path:       dir,
+		Prefix:     "/static/",
+		HashLength: 6,
+	})
+	assert.Nil(err)
+	sum := sha256.Sum256([]byte("var a = 1;"))
+	hashedName := "js/app." + hex.EncodeToString(sum[:])[:6] + ".js"
+	assert.Equal(2, len(m.Assets()))
+	assert.Equal("/static/"+hashedName, m.AssetURL("js/app.js"))
+	assert.Equal("/static/"+hashedName, m.AssetURL("/js/app.js"))
+	assert.Equal("/static/notfound.js", m.AssetURL("notfound.js"))
+
+	name, ok := m.Resolve("/" + hashedName)
+	assert.True(ok)
+	assert.Equal("js/app.js", name)
+	_, ok = m.Resolve("js/app.js")
+	assert.False(ok)
+
+	fn := NewStaticServe(sf, StaticServeConfig{
+		Path:     dir,
+		MaxAge:   60,
+		Manifest: m,
+	})
+	newContext := func(url string) *elton.Context {
+		c := elton.NewContext(httptest.NewRecorder(), httptest.NewRequest("GET", url, nil))
+		c.Next = func() error {
+			return nil
+		}
+		return c
+	}
+	c := newContext("/" + hashedName)
+	assert.Nil(fn(c))
+	assert.Equal("public, max-age=31536000, immutable", c.GetHeader(elton.HeaderCacheControl))
+	assert.Contains(c.GetHeader(elton.HeaderContentType), "javascript")
+
+	c = newContext("/js/app.js")
+	assert.Nil(fn(c))
+	assert.Equal("no-cache", c.GetHeader(elton.HeaderCacheControl))
+
+	// 文件修改后重新加载
+	assert.Nil(ioutil.WriteFile(filepath.Join(dir, "js/app.js"), []byte("var a = 2;"), 0600))
+	assert.Nil(m.Reload())
+	assert.NotEqual("/static/"+hashedName, m.AssetURL("js/app.js"))
+
+	// hash长度超出则使用完整的hash
+	m, err = NewAssetManifest(sf, AssetManifestConfig{
+		Path:       dir,
+		HashLength: 100,
+	})
+	assert.Nil(err)
+	sum = sha256.Sum256([]byte("var a = 2;"))
+	assert.Equal("/js/app."+hex.EncodeToString(sum[:])+".js", m.AssetURL("js/app.js"))
+
+	_, err = NewAssetManifest(&MockStaticFile{}, AssetManifestConfig{})
+	assert.Equal(errStaticDirNotSupported, err)
+}
@@ -31,6 +31,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
+	gopath "path"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -72,7 +73,17 @@ type (
 		DisableLastModified bool
 		// 如果404，是否调用next执行后续的中间件（默认为不执行，返回404错误）
 		NotFoundNext bool
-		Skipper      elton.Skipper
+		// 是否查找预压缩的文件(.br, .zst, .gz)，根据Accept-Encoding返回最优的压缩文件
+		EnablePrecompressed bool
+		// 目录的默认文件，如index.html
+		Index string
+		// 是否允许列出目录的文件（如果有Index文件则返回Index文件）
+		EnableListing bool
+		// 单页应用的fallback文件，非静态资源（无扩展名）的路径如果不存在则返回此文件
+		SPAFallback string
+		// 静态文件的manifest，带hash的文件使用immutable的长期缓存，其它文件则需要重新校验
+		Manifest *AssetManifest
+		Skipper  elton.Skipper
 	}
 	// FS file system
 	FS struct {
@@ -120,6 +131,52 @@ func (fs *FS) NewReader(file string) (io.Reader, error) {
 	return os.Open(file)
 }
 
+// staticPrecompressedFiles the encoding and extension of precompressed file,
+// it's ordered by priority when the q-values are the same
+var staticPrecompressedFiles = []struct {
+	encoding string
+	ext      string
+}{
+	{
+		encoding: BrEncoding,
+		ext:      ".br",
+	},
+	{
+		encoding: ZstdEncoding,
+		ext:      ".zst",
+	},
+	{
+		encoding: GzipEncoding,
+		ext:      ".gz",
+	},
+}
+
+// getPrecompressedFile returns the precompressed file which is the best one the client accepts,
+// and sets the Vary header if there is any precompressed file
+func getPrecompressedFile(c *elton.Context, staticFile StaticFile, file string) (string, string) {
+	found := false
+	precompressedFile := ""
+	encoding := ""
+	quality := 0.0
+	for _, item := range staticPrecompressedFiles {
+		f := file + item.ext
+		if !staticFile.Exists(f) {
+			continue
+		}
+		found = true
+		q := AcceptEncodingQuality(c, item.encoding)
+		if q > quality {
+			precompressedFile = f
+			encoding = item.encoding
+			quality = q
+		}
+	}
+	if found {
+		addVary(c, elton.HeaderAcceptEncoding)
+	}
+	return precompressedFile, encoding
+}
+
 // getStaticServeError 获取static serve的出错
 func getStaticServeError(message string, statusCode int) *hes.Error {
 	return &hes.Error{
@@ -156,6 +213,12 @@ func toSeconds(d time.Duration) string {
 // NewStaticServe returns a new static serve middleware, suggest to set the MaxAge and SMaxAge for cache control for better performance.
 // It will return an error if DenyDot is true and filename is start with '.'.
 // It will return an error if DenyQueryString is true and the querystring is not empty.
+// If Index is set, the index file will be served for directory, and the directory can be listed as html or json
+// if EnableListing is true, the request of directory without trailing slash will be redirected(301).
+// If SPAFallback is set, the fallback file will be served for the unknown path without extension.
+// If Manifest is set, the fingerprinted file will be served with immutable long-lived caching, and the others with no-cache.
+// If EnablePrecompressed is true, the sibling .br/.zst/.gz file which the client accepts will be served
+// with Content-Encoding, and the ETag is generated from the precompressed file.
 func NewStaticServe(staticFile StaticFile, config StaticServeConfig) elton.Handler {
 	cacheArr := []string{
 		"public",
@@ -195,6 +258,15 @@ func NewStaticServe(staticFile StaticFile, config StaticServeConfig) elton.Handl
 			file = url.Path
 		}
 
+		fingerprinted := false
+		if config.Manifest != nil {
+			name, ok := config.Manifest.Resolve(file)
+			if ok {
+				file = name
+				fingerprinted = true
+			}
+		}
+
 		file = filepath.Join(config.Path, file)
 		// 避免文件名是有 .. 等导致最终文件路径越过配置的路径
 		if !strings.HasPrefix(file, basePath) {
@@ -219,6 +291,40 @@ func NewStaticServe(staticFile StaticFile, config StaticServeConfig) elton.Handl
 			return
 		}
 		exists := staticFile.Exists(file)
+		if exists && (config.Index != "" || config.EnableListing) && isStaticDir(staticFile, file) {
+			// 目录需要以/结尾，保证相对路径的正确，
+			// 使用相对路径跳转，避免挂载在其它前缀下时跳转错误
+			if !strings.HasSuffix(url.Path, "/") {
+				redirectURL := "./" + gopath.Base(url.Path) + "/"
+				if url.RawQuery != "" {
+					redirectURL += "?" + url.RawQuery
+				}
+				// http.Redirect会将相对路径转换为绝对路径，因此直接设置Location
+				c.SetHeader(elton.HeaderLocation, redirectURL)
+				c.StatusCode = http.StatusMovedPermanently
+				return nil
+			}
+			indexFile := ""
+			if config.Index != "" {
+				indexFile = filepath.Join(file, config.Index)
+			}
+			if indexFile != "" && staticFile.Exists(indexFile) {
+				file = indexFile
+			} else if config.EnableListing {
+				err = listStaticDir(c, staticFile, file, config.DenyDot)
+				if err != nil {
+					return
+				}
+				return c.Next()
+			} else {
+				exists = false
+			}
+		}
+		// 无扩展名的路径（非静态资源）则返回fallback文件
+		if !exists && config.SPAFallback != "" && filepath.Ext(url.Path) == "" {
+			file = filepath.Join(config.Path, config.SPAFallback)
+			exists = staticFile.Exists(file)
+		}
 		if !exists {
 			if config.NotFoundNext {
 				return c.Next()
@@ -228,6 +334,13 @@ func NewStaticServe(staticFile StaticFile, config StaticServeConfig) elton.Handl
 		}
 
 		c.SetContentTypeByExt(file)
+		if config.EnablePrecompressed {
+			precompressedFile, encoding := getPrecompressedFile(c, staticFile, file)
+			if precompressedFile != "" {
+				c.SetHeader(elton.HeaderContentEncoding, encoding)
+				file = precompressedFile
+			}
+		}
 		var fileBuf []byte
 		// strong eTag需要读取文件内容计算eTag
 		if !config.DisableETag && config.EnableStrongETag {
@@ -246,7 +359,14 @@ func NewStaticServe(staticFile StaticFile, config StaticServeConfig) elton.Handl
 
 		if !config.DisableETag {
 			if config.EnableStrongETag {
-				eTag := generateETag(fileBuf)
+				eTag := ""
+				// 如果支持则使用预先生成的etag，避免每次计算
+				if eTagFile, ok := staticFile.(StaticFileETag); ok {
+					eTag = eTagFile.ETag(file)
+				}
+				if eTag == "" {
+					eTag = generateETag(fileBuf)
+				}
 				if eTag != "" {
 					c.SetHeader(elton.HeaderETag, eTag)
 				}
@@ -270,7 +390,9 @@ func NewStaticServe(staticFile StaticFile, config StaticServeConfig) elton.Handl
 		for k, v := range config.Header {
 			c.SetHeader(k, v)
 		}
-		if cacheControl != "" {
+		if fingerprinted {
+			c.SetHeader(elton.HeaderCacheControl, assetCacheControl)
+		} else if cacheControl != "" && config.Manifest == nil {
 			c.SetHeader(elton.HeaderCacheControl, cacheControl)
 		} else {
 			c.NoCache()
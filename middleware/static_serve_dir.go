@@ -0,0 +1,209 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vicanso/elton"
+)
+
+type (
+	// StaticDirFile the static file supports directory
+	StaticDirFile interface {
+		// IsDir checks the file is directory
+		IsDir(string) bool
+		// ReadDir returns the file info list of directory
+		ReadDir(string) ([]os.FileInfo, error)
+	}
+	// StaticDirEntry the entry of directory listing
+	StaticDirEntry struct {
+		Name    string    `json:"name"`
+		Size    int64     `json:"size"`
+		ModTime time.Time `json:"modTime,omitempty"`
+		IsDir   bool      `json:"isDir"`
+	}
+)
+
+const (
+	staticListingSortName    = "name"
+	staticListingSortSize    = "size"
+	staticListingSortModTime = "modTime"
+)
+
+var staticListingTemplate = template.Must(template.New("listing").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Index of {{.Path}}</title>
+</head>
+<body>
+<h1>Index of {{.Path}}</h1>
+<table>
+<tr><th><a href="?sort=name">Name</a></th><th><a href="?sort=size">Size</a></th><th><a href="?sort=modTime">Modified</a></th></tr>
+{{range .Entries}}<tr><td><a href="{{.Name}}{{if .IsDir}}/{{end}}">{{.Name}}{{if .IsDir}}/{{end}}</a></td><td>{{if not .IsDir}}{{.Size}}{{end}}</td><td>{{if not .ModTime.IsZero}}{{.ModTime.UTC.Format "2006-01-02 15:04:05"}}{{end}}</td></tr>
+{{end}}</table>
+</body>
+</html>`))
+
+// IsDir checks the file is directory
+func (fs *FS) IsDir(file string) bool {
+	info, err := os.Stat(file)
+	if err != nil {
+		return false
+	}
+	return info.IsDir()
+}
+
+// ReadDir returns the file info list of directory
+func (fs *FS) ReadDir(file string) ([]os.FileInfo, error) {
+	return ioutil.ReadDir(file)
+}
+
+// IsDir checks the file is directory
+func (es *embedStaticFS) IsDir(file string) bool {
+	info, err := fs.Stat(es.FS, es.getFile(file))
+	if err != nil {
+		return false
+	}
+	return info.IsDir()
+}
+
+// ReadDir returns the file info list of directory
+func (es *embedStaticFS) ReadDir(file string) ([]os.FileInfo, error) {
+	entries, err := es.FS.ReadDir(es.getFile(file))
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// isStaticDir checks the file is directory
+func isStaticDir(staticFile StaticFile, file string) bool {
+	if dirFile, ok := staticFile.(StaticDirFile); ok {
+		return dirFile.IsDir(file)
+	}
+	info := staticFile.Stat(file)
+	return info != nil && info.IsDir()
+}
+
+// sortStaticDirEntries sorts the entries, the directory is always before the file
+func sortStaticDirEntries(entries []*StaticDirEntry, by string, desc bool) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a := entries[i]
+		b := entries[j]
+		if a.IsDir != b.IsDir {
+			return a.IsDir
+		}
+		var less bool
+		switch by {
+		case staticListingSortSize:
+			if a.Size == b.Size {
+				less = a.Name < b.Name
+			} else {
+				less = a.Size < b.Size
+			}
+		case staticListingSortModTime:
+			if a.ModTime.Equal(b.ModTime) {
+				less = a.Name < b.Name
+			} else {
+				less = a.ModTime.Before(b.ModTime)
+			}
+		default:
+			less = a.Name < b.Name
+		}
+		if desc {
+			return !less
+		}
+		return less
+	})
+}
+
+// listStaticDir lists the directory as html or json(the request accepts json),
+// it's sorted by querystring sort(name, size or modTime) and order(asc or desc)
+func listStaticDir(c *elton.Context, staticFile StaticFile, file string, denyDot bool) error {
+	dirFile, ok := staticFile.(StaticDirFile)
+	if !ok {
+		return ErrStaticServeNotFound
+	}
+	infos, err := dirFile.ReadDir(file)
+	if err != nil {
+		return getStaticServeError(err.Error(), http.StatusInternalServerError)
+	}
+	entries := make([]*StaticDirEntry, 0, len(infos))
+	for _, info := range infos {
+		name := info.Name()
+		if denyDot && strings.HasPrefix(name, ".") {
+			continue
+		}
+		entries = append(entries, &StaticDirEntry{
+			Name:    name,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			IsDir:   info.IsDir(),
+		})
+	}
+	sortStaticDirEntries(entries, c.QueryParam("sort"), c.QueryParam("order") == "desc")
+	c.NoCache()
+	c.StatusCode = http.StatusOK
+	// 根据Accept返回json或html
+	addVary(c, "Accept")
+	if strings.Contains(c.GetRequestHeader("Accept"), "application/json") {
+		buf, err := json.Marshal(entries)
+		if err != nil {
+			return err
+		}
+		c.SetHeader(elton.HeaderContentType, elton.MIMEApplicationJSON)
+		c.BodyBuffer = bytes.NewBuffer(buf)
+		return nil
+	}
+	buffer := new(bytes.Buffer)
+	err = staticListingTemplate.Execute(buffer, map[string]interface{}{
+		"Path":    filepath.ToSlash(c.Request.URL.Path),
+		"Entries": entries,
+	})
+	if err != nil {
+		return err
+	}
+	c.SetHeader(elton.HeaderContentType, elton.MIMETextHTML)
+	c.BodyBuffer = buffer
+	return nil
+}
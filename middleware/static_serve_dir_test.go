@@ -0,0 +1,184 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vicanso/elton"
+)
+
+func newStaticDirForTest(t *testing.T) string {
+	dir := t.TempDir()
+	files := map[string]string{
+		"index.html":        "<html>index</html>",
+		"docs/b.txt":        "bb",
+		"docs/a.txt":        "aaaa",
+		"docs/.secret":      "secret",
+		"docs/sub/c.txt":    "c",
+		"app/static/app.js": "app",
+	}
+	for name, data := range files {
+		file := filepath.Join(dir, name)
+		_ = os.MkdirAll(filepath.Dir(file), 0700)
+		_ = ioutil.WriteFile(file, []byte(data), 0600)
+	}
+	return dir
+}
+
+func TestSortStaticDirEntries(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	entries := []*StaticDirEntry{
+		{
+			Name:    "b",
+			Size:    1,
+			ModTime: now,
+		},
+		{
+			Name:    "a",
+			Size:    2,
+			ModTime: now.Add(time.Second),
+		},
+		{
+			Name:  "z",
+			IsDir: true,
+		},
+	}
+	getNames := func() []string {
+		names := make([]string, 0)
+		for _, entry := range entries {
+			names = append(names, entry.Name)
+		}
+		return names
+	}
+	sortStaticDirEntries(entries, "", false)
+	assert.Equal([]string{"z", "a", "b"}, getNames())
+	sortStaticDirEntries(entries, staticListingSortSize, false)
+	assert.Equal([]string{"z", "b", "a"}, getNames())
+	sortStaticDirEntries(entries, staticListingSortModTime, true)
+	assert.Equal([]string{"z", "a", "b"}, getNames())
+}
+
+func TestStaticServeDir(t *testing.T) {
+	assert := assert.New(t)
+	dir := newStaticDirForTest(t)
+	fn := NewDefaultStaticServe(StaticServeConfig{
+		Path:             dir,
+		Index:            "index.html",
+		EnableListing:    true,
+		EnableStrongETag: true,
+		DenyDot:          true,
+		SPAFallback:      "index.html",
+	})
+	newContext := func(url string) *elton.Context {
+		c := elton.NewContext(httptest.NewRecorder(), httptest.NewRequest("GET", url, nil))
+		c.Next = func() error {
+			return nil
+		}
+		return c
+	}
+
+	t.Run("index", func(t *testing.T) {
+		c := newContext("/")
+		assert.Nil(fn(c))
+		assert.Equal("<html>index</html>", c.BodyBuffer.String())
+	})
+
+	t.Run("redirect", func(t *testing.T) {
+		c := newContext("/docs?sort=size")
+		assert.Nil(fn(c))
+		assert.Equal(301, c.StatusCode)
+		assert.Equal("./docs/?sort=size", c.GetHeader(elton.HeaderLocation))
+
+		// 使用相对路径，挂载在其它前缀下也可正确跳转
+		c = newContext("/docs/sub")
+		assert.Nil(fn(c))
+		assert.Equal(301, c.StatusCode)
+		assert.Equal("./sub/", c.GetHeader(elton.HeaderLocation))
+	})
+
+	t.Run("html listing", func(t *testing.T) {
+		c := newContext("/docs/")
+		assert.Nil(fn(c))
+		assert.Equal("text/html; charset=utf-8", c.GetHeader(elton.HeaderContentType))
+		assert.Equal("Accept", c.GetHeader(HeaderVary))
+		html := c.BodyBuffer.String()
+		assert.Contains(html, "Index of /docs/")
+		assert.Contains(html, `<a href="sub/">sub/</a>`)
+		assert.Contains(html, `<a href="a.txt">a.txt</a>`)
+		assert.NotContains(html, ".secret")
+	})
+
+	t.Run("json listing", func(t *testing.T) {
+		c := newContext("/docs/?sort=size&order=desc")
+		c.Request.Header.Set("Accept", "application/json")
+		assert.Nil(fn(c))
+		assert.Equal("Accept", c.GetHeader(HeaderVary))
+		entries := make([]*StaticDirEntry, 0)
+		assert.Nil(json.Unmarshal(c.BodyBuffer.Bytes(), &entries))
+		names := make([]string, 0)
+		for _, entry := range entries {
+			names = append(names, entry.Name)
+		}
+		assert.Equal([]string{"sub", "a.txt", "b.txt"}, names)
+	})
+
+	t.Run("spa fallback", func(t *testing.T) {
+		c := newContext("/users/me")
+		assert.Nil(fn(c))
+		assert.Equal("<html>index</html>", c.BodyBuffer.String())
+
+		// 静态资源不返回fallback
+		c = newContext("/users/me.js")
+		assert.Equal(ErrStaticServeNotFound, fn(c))
+	})
+}
+
+func TestStaticServeDirWithoutListing(t *testing.T) {
+	assert := assert.New(t)
+	dir := newStaticDirForTest(t)
+	fn := NewDefaultStaticServe(StaticServeConfig{
+		Path:  dir,
+		Index: "index.html",
+	})
+	c := elton.NewContext(httptest.NewRecorder(), httptest.NewRequest("GET", "/docs/", nil))
+	assert.Equal(ErrStaticServeNotFound, fn(c))
+}
+
+func TestEmbedStaticFSDir(t *testing.T) {
+	assert := assert.New(t)
+	fs := NewEmbedStaticFS(assetFS, "")
+	assert.True(fs.IsDir("."))
+	assert.False(fs.IsDir("static_embed.go"))
+	infos, err := fs.ReadDir(".")
+	assert.Nil(err)
+	assert.NotEmpty(infos)
+}
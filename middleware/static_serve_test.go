@@ -26,8 +26,10 @@ import (
 	"bytes"
 	"errors"
 	"io"
+	"io/ioutil"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -252,3 +254,81 @@ func TestStaticServe(t *testing.T) {
 		assert.Equal(tt.cacheControl, c.GetHeader(elton.HeaderCacheControl))
 	}
 }
+
+func TestStaticServePrecompressed(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	files := map[string]string{
+		"app.js":     "original",
+		"app.js.br":  "br data",
+		"app.js.gz":  "gzip data",
+		"index.html": "<html></html>",
+	}
+	for name, data := range files {
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0600)
+		assert.Nil(err)
+	}
+	fn := NewDefaultStaticServe(StaticServeConfig{
+		Path:                dir,
+		EnableStrongETag:    true,
+		EnablePrecompressed: true,
+	})
+
+	tests := []struct {
+		url            string
+		acceptEncoding string
+		encoding       string
+		body           string
+		vary           string
+	}{
+		{
+			url:            "/app.js",
+			acceptEncoding: "gzip, br",
+			encoding:       BrEncoding,
+			body:           "br data",
+			vary:           elton.HeaderAcceptEncoding,
+		},
+		{
+			url:            "/app.js",
+			acceptEncoding: "gzip, deflate, zstd",
+			encoding:       GzipEncoding,
+			body:           "gzip data",
+			vary:           elton.HeaderAcceptEncoding,
+		},
+		{
+			url:            "/app.js",
+			acceptEncoding: "br;q=0.5, gzip",
+			encoding:       GzipEncoding,
+			body:           "gzip data",
+			vary:           elton.HeaderAcceptEncoding,
+		},
+		{
+			url:  "/app.js",
+			body: "original",
+			vary: elton.HeaderAcceptEncoding,
+		},
+		{
+			url:            "/index.html",
+			acceptEncoding: "gzip, br",
+			body:           "<html></html>",
+		},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.url, nil)
+		req.Header.Set(elton.HeaderAcceptEncoding, tt.acceptEncoding)
+		c := elton.NewContext(httptest.NewRecorder(), req)
+		c.Next = func() error {
+			return nil
+		}
+		err := fn(c)
+		assert.Nil(err)
+		assert.Equal(tt.encoding, c.GetHeader(elton.HeaderContentEncoding))
+		assert.Equal(tt.vary, c.GetHeader(HeaderVary))
+		// 根据原文件设置数据类型，etag则根据压缩文件生成
+		assert.Equal(generateETag([]byte(tt.body)), c.GetHeader(elton.HeaderETag))
+		if tt.url == "/app.js" {
+			assert.Contains(c.GetHeader(elton.HeaderContentType), "javascript")
+		}
+		assert.Equal(tt.body, c.BodyBuffer.String())
+	}
+}
@@ -113,11 +113,7 @@ func NewStats(config StatsConfig) elton.Handler {
 		}
 		info.Status = status
 		info.Type = status / 100
-		size := 0
-		if c.BodyBuffer != nil {
-			size = c.BodyBuffer.Len()
-		}
-		info.Size = size
+		info.Size = c.ResponseSize()
 
 		config.OnStats(info, c)
 		return
@@ -0,0 +1,120 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/vicanso/elton"
+)
+
+const (
+	// ZstdEncoding zstd encoding
+	ZstdEncoding = "zstd"
+)
+
+type (
+	// ZstdCompressor zstd compress
+	ZstdCompressor struct {
+		// Level the level of zstd(1-22), it will be converted to the nearest encoder level
+		Level     int
+		MinLength int
+		pool      sync.Pool
+	}
+)
+
+// Accept accept zstd encoding
+func (z *ZstdCompressor) Accept(c *elton.Context, bodySize int) (acceptable bool, encoding string) {
+	// 如果数据少于最低压缩长度，则不压缩（reader的bodySize为-1）
+	if bodySize >= 0 && bodySize < z.getMinLength() {
+		return
+	}
+	return AcceptEncoding(c, ZstdEncoding)
+}
+
+// Compress compress data by zstd
+func (z *ZstdCompressor) Compress(buf []byte) (*bytes.Buffer, error) {
+	buffer := new(bytes.Buffer)
+	w := z.Writer(buffer)
+	defer z.Release(w)
+	_, err := w.Write(buf)
+	if err != nil {
+		return nil, err
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buffer, nil
+}
+
+// Writer returns a zstd writer from pool
+func (z *ZstdCompressor) Writer(w io.Writer) CompressWriter {
+	if v := z.pool.Get(); v != nil {
+		zw := v.(*zstd.Encoder)
+		zw.Reset(w)
+		return zw
+	}
+	// 仅设置了level以及并发数，不会出错
+	zw, _ := zstd.NewWriter(w, zstd.WithEncoderLevel(z.getLevel()), zstd.WithEncoderConcurrency(1))
+	return zw
+}
+
+// Release puts the zstd writer back to pool
+func (z *ZstdCompressor) Release(w CompressWriter) {
+	w.Reset(nil)
+	z.pool.Put(w)
+}
+
+func (z *ZstdCompressor) getLevel() zstd.EncoderLevel {
+	if z.Level <= 0 {
+		return zstd.SpeedDefault
+	}
+	return zstd.EncoderLevelFromZstd(z.Level)
+}
+
+func (z *ZstdCompressor) getMinLength() int {
+	if z.MinLength == 0 {
+		return DefaultCompressMinLength
+	}
+	return z.MinLength
+}
+
+// Pipe compress by pipe
+func (z *ZstdCompressor) Pipe(c *elton.Context) (err error) {
+	r := c.Body.(io.Reader)
+	closer, ok := c.Body.(io.Closer)
+	if ok {
+		defer closer.Close()
+	}
+	w := z.Writer(c.Response)
+	defer z.Release(w)
+	_, err = io.Copy(w, r)
+	if err != nil {
+		return
+	}
+	return w.Close()
+}
@@ -0,0 +1,216 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package elton
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// PaginationPageQuery the query name of page
+	PaginationPageQuery = "page"
+	// PaginationLimitQuery the query name of limit
+	PaginationLimitQuery = "limit"
+	// PaginationCursorQuery the query name of cursor
+	PaginationCursorQuery = "cursor"
+	// PaginationKey the key of pagination which is stored in context
+	PaginationKey = "_pagination"
+)
+
+// Pagination the pagination of list response,
+// it supports page pagination(page and limit) and cursor pagination(cursor and limit).
+type Pagination struct {
+	// Page the page number, starts from 1
+	Page int
+	// Limit the max count of each page
+	Limit int
+	// Cursor the cursor of current page
+	Cursor string
+	// NextCursor the cursor of next page, it should be set by handler for cursor pagination
+	NextCursor string
+	// Total the total count, -1 means unknown
+	Total int64
+}
+
+// IsCursor returns true if it's cursor pagination
+func (p *Pagination) IsCursor() bool {
+	return p.Cursor != "" || p.NextCursor != ""
+}
+
+// Offset returns the offset of page pagination
+func (p *Pagination) Offset() int {
+	if p.Page <= 1 {
+		return 0
+	}
+	return (p.Page - 1) * p.Limit
+}
+
+// TotalPages returns the total pages of page pagination, -1 means unknown
+func (p *Pagination) TotalPages() int64 {
+	if p.Total < 0 || p.Limit <= 0 {
+		return -1
+	}
+	limit := int64(p.Limit)
+	return (p.Total + limit - 1) / limit
+}
+
+// Meta returns the meta data of pagination, total will be ignored if it's unknown
+func (p *Pagination) Meta() map[string]interface{} {
+	meta := map[string]interface{}{
+		PaginationLimitQuery: p.Limit,
+	}
+	if p.IsCursor() {
+		if p.Cursor != "" {
+			meta[PaginationCursorQuery] = p.Cursor
+		}
+		if p.NextCursor != "" {
+			meta["nextCursor"] = p.NextCursor
+		}
+	} else {
+		meta[PaginationPageQuery] = p.Page
+	}
+	if p.Total >= 0 {
+		meta["total"] = p.Total
+		if !p.IsCursor() {
+			meta["totalPages"] = p.TotalPages()
+		}
+	}
+	return meta
+}
+
+// Link returns the value of link header(RFC 8288) for pagination,
+// the links are generated from the url.
+func (p *Pagination) Link(u *url.URL) string {
+	genLink := func(rel string, values map[string]string) string {
+		query := u.Query()
+		for k, v := range values {
+			if v == "" {
+				query.Del(k)
+			} else {
+				query.Set(k, v)
+			}
+		}
+		link := *u
+		link.RawQuery = query.Encode()
+		return fmt.Sprintf(`<%s>; rel="%s"`, link.String(), rel)
+	}
+	limit := strconv.Itoa(p.Limit)
+	links := make([]string, 0, 4)
+	if p.IsCursor() {
+		links = append(links, genLink("first", map[string]string{
+			PaginationCursorQuery: "",
+			PaginationPageQuery:   "",
+			PaginationLimitQuery:  limit,
+		}))
+		if p.NextCursor != "" {
+			links = append(links, genLink("next", map[string]string{
+				PaginationCursorQuery: p.NextCursor,
+				PaginationPageQuery:   "",
+				PaginationLimitQuery:  limit,
+			}))
+		}
+		return strings.Join(links, ", ")
+	}
+
+	genPageLink := func(rel string, page int64) string {
+		return genLink(rel, map[string]string{
+			PaginationPageQuery:  strconv.FormatInt(page, 10),
+			PaginationLimitQuery: limit,
+		})
+	}
+	page := int64(p.Page)
+	totalPages := p.TotalPages()
+	links = append(links, genPageLink("first", 1))
+	if page > 1 {
+		links = append(links, genPageLink("prev", page-1))
+	}
+	// 总数未知时无法判断是否有下一页
+	if totalPages >= 0 {
+		if page < totalPages {
+			links = append(links, genPageLink("next", page+1))
+		}
+		if totalPages > 0 {
+			links = append(links, genPageLink("last", totalPages))
+		}
+	}
+	return strings.Join(links, ", ")
+}
+
+// ParsePagination parses the pagination from the query(page, limit and cursor) of request,
+// and sets it to context. The limit will be defaultLimit if it's not set,
+// and it will be maxLimit if it's greater than maxLimit.
+func (c *Context) ParsePagination(defaultLimit, maxLimit int) (*Pagination, error) {
+	p := &Pagination{
+		Page:   1,
+		Limit:  defaultLimit,
+		Cursor: c.QueryParam(PaginationCursorQuery),
+		Total:  -1,
+	}
+	parseInt := func(name string) (int, bool, error) {
+		v := c.QueryParam(name)
+		if v == "" {
+			return 0, false, nil
+		}
+		value, err := strconv.Atoi(v)
+		if err != nil || value <= 0 {
+			return 0, false, ErrInvalidPagination
+		}
+		return value, true, nil
+	}
+	page, exists, err := parseInt(PaginationPageQuery)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		p.Page = page
+	}
+	limit, exists, err := parseInt(PaginationLimitQuery)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		p.Limit = limit
+	}
+	if maxLimit > 0 && p.Limit > maxLimit {
+		p.Limit = maxLimit
+	}
+	c.SetPagination(p)
+	return p, nil
+}
+
+// SetPagination sets the pagination of response to context
+func (c *Context) SetPagination(p *Pagination) {
+	c.Set(PaginationKey, p)
+}
+
+// GetPagination returns the pagination of response from context
+func (c *Context) GetPagination() *Pagination {
+	value, exists := c.Get(PaginationKey)
+	if !exists {
+		return nil
+	}
+	p, _ := value.(*Pagination)
+	return p
+}
@@ -0,0 +1,133 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package elton
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePagination(t *testing.T) {
+	assert := assert.New(t)
+
+	req := httptest.NewRequest("GET", "/users", nil)
+	c := NewContext(nil, req)
+	assert.Nil(c.GetPagination())
+	p, err := c.ParsePagination(10, 100)
+	assert.Nil(err)
+	assert.Equal(&Pagination{
+		Page:  1,
+		Limit: 10,
+		Total: -1,
+	}, p)
+	assert.Equal(p, c.GetPagination())
+	assert.Equal(0, p.Offset())
+	assert.False(p.IsCursor())
+
+	req = httptest.NewRequest("GET", "/users?page=3&limit=1000", nil)
+	c = NewContext(nil, req)
+	p, err = c.ParsePagination(10, 100)
+	assert.Nil(err)
+	assert.Equal(3, p.Page)
+	assert.Equal(100, p.Limit)
+	assert.Equal(200, p.Offset())
+
+	req = httptest.NewRequest("GET", "/users?cursor=abc&limit=5", nil)
+	c = NewContext(nil, req)
+	p, err = c.ParsePagination(10, 100)
+	assert.Nil(err)
+	assert.Equal("abc", p.Cursor)
+	assert.Equal(5, p.Limit)
+	assert.True(p.IsCursor())
+
+	for _, query := range []string{
+		"page=0",
+		"page=a",
+		"limit=-1",
+	} {
+		req = httptest.NewRequest("GET", "/users?"+query, nil)
+		c = NewContext(nil, req)
+		_, err = c.ParsePagination(10, 100)
+		assert.Equal(ErrInvalidPagination, err)
+	}
+}
+
+func TestPaginationMeta(t *testing.T) {
+	assert := assert.New(t)
+
+	p := &Pagination{
+		Page:  1,
+		Limit: 10,
+		Total: 21,
+	}
+	assert.Equal(int64(3), p.TotalPages())
+	assert.Equal(map[string]interface{}{
+		"page":       1,
+		"limit":      10,
+		"total":      int64(21),
+		"totalPages": int64(3),
+	}, p.Meta())
+
+	p.Total = -1
+	assert.Equal(int64(-1), p.TotalPages())
+	assert.Equal(map[string]interface{}{
+		"page":  1,
+		"limit": 10,
+	}, p.Meta())
+
+	p = &Pagination{
+		Limit:      10,
+		Cursor:     "a",
+		NextCursor: "b",
+		Total:      -1,
+	}
+	assert.Equal(map[string]interface{}{
+		"limit":      10,
+		"cursor":     "a",
+		"nextCursor": "b",
+	}, p.Meta())
+}
+
+func TestPaginationLink(t *testing.T) {
+	assert := assert.New(t)
+	u, _ := url.Parse("/users?type=1&page=2")
+
+	p := &Pagination{
+		Page:  2,
+		Limit: 10,
+		Total: 21,
+	}
+	assert.Equal(`</users?limit=10&page=1&type=1>; rel="first", </users?limit=10&page=1&type=1>; rel="prev", </users?limit=10&page=3&type=1>; rel="next", </users?limit=10&page=3&type=1>; rel="last"`, p.Link(u))
+
+	// 总数未知
+	p.Total = -1
+	assert.Equal(`</users?limit=10&page=1&type=1>; rel="first", </users?limit=10&page=1&type=1>; rel="prev"`, p.Link(u))
+
+	p = &Pagination{
+		Limit:      10,
+		NextCursor: "b",
+	}
+	assert.Equal(`</users?limit=10&type=1>; rel="first", </users?cursor=b&limit=10&type=1>; rel="next"`, p.Link(u))
+}
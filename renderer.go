@@ -0,0 +1,239 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package elton
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"mime"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultLayoutDir default directory of layout templates
+	DefaultLayoutDir = "layouts"
+	// DefaultPartialDir default directory of partial templates
+	DefaultPartialDir = "partials"
+	// LayoutContentName the template name of content, the layout is used only if the page defines it
+	LayoutContentName = "content"
+)
+
+type (
+	// Renderer template renderer
+	Renderer interface {
+		// Render renders the template of name to w
+		Render(w io.Writer, name string, data interface{}) error
+	}
+	// HTMLRendererConfig html renderer config
+	HTMLRendererConfig struct {
+		// FS the file system of templates, such as os.DirFS or embed.FS
+		FS fs.FS
+		// LayoutDir the directory of layouts, default is layouts
+		LayoutDir string
+		// PartialDir the directory of partials, default is partials
+		PartialDir string
+		// Layout the default layout file(relative to layout dir), e.g. base.html
+		Layout string
+		// Funcs the custom template functions
+		Funcs template.FuncMap
+		// AssetURL the function of asset url, it's used by template function asset
+		AssetURL func(name string) string
+		// Dev reloads the templates every render if it's true
+		Dev bool
+	}
+	// HTMLRenderer html template renderer, the template set of page includes all layouts and partials,
+	// the template name is the path relative to the root of fs, e.g. partials/header.html
+	HTMLRenderer struct {
+		config    HTMLRendererConfig
+		funcs     template.FuncMap
+		templates sync.Map
+	}
+)
+
+var routeParamReg = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// ReverseURL returns the url of route with params, the params is key-value pairs,
+// e.g. ReverseURL("/users/{id}", "id", 1) returns /users/1.
+// The param values are escaped, and each segment of wildcard value is escaped.
+func ReverseURL(route string, params ...interface{}) (string, error) {
+	if len(params)%2 != 0 {
+		return "", errors.New("params should be key-value pairs")
+	}
+	values := make(map[string]string, len(params)/2)
+	for i := 0; i < len(params); i += 2 {
+		values[fmt.Sprint(params[i])] = fmt.Sprint(params[i+1])
+	}
+	var err error
+	result := routeParamReg.ReplaceAllStringFunc(route, func(s string) string {
+		name := routeParamReg.FindStringSubmatch(s)[1]
+		value, ok := values[name]
+		if !ok && err == nil {
+			err = fmt.Errorf("param %s of route %s is missing", name, route)
+		}
+		return url.PathEscape(value)
+	})
+	if err != nil {
+		return "", err
+	}
+	// 通配符
+	if strings.HasSuffix(result, "*") {
+		// 通配符的值可包括多级路径，按层级转义
+		segments := strings.Split(values["*"], "/")
+		for index, segment := range segments {
+			segments[index] = url.PathEscape(segment)
+		}
+		result = strings.TrimSuffix(result, "*") + strings.Join(segments, "/")
+	}
+	return result, nil
+}
+
+// NewHTMLRenderer returns a new html renderer,
+// the template functions url(reverse url), asset(asset url) and safeHTML are added.
+func NewHTMLRenderer(config HTMLRendererConfig) *HTMLRenderer {
+	if config.LayoutDir == "" {
+		config.LayoutDir = DefaultLayoutDir
+	}
+	if config.PartialDir == "" {
+		config.PartialDir = DefaultPartialDir
+	}
+	assetURL := config.AssetURL
+	if assetURL == nil {
+		assetURL = func(name string) string {
+			return name
+		}
+	}
+	funcs := template.FuncMap{
+		"url":   ReverseURL,
+		"asset": assetURL,
+		"safeHTML": func(s string) template.HTML {
+			return template.HTML(s)
+		},
+	}
+	for name, fn := range config.Funcs {
+		funcs[name] = fn
+	}
+	return &HTMLRenderer{
+		config: config,
+		funcs:  funcs,
+	}
+}
+
+// parseDir parses all files of dir, it will be ignored if the dir is not exists
+func (r *HTMLRenderer) parseDir(t *template.Template, dir string) error {
+	if _, err := fs.Stat(r.config.FS, dir); err != nil {
+		return nil
+	}
+	return fs.WalkDir(r.config.FS, dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		return r.parseFile(t, file)
+	})
+}
+
+func (r *HTMLRenderer) parseFile(t *template.Template, file string) error {
+	buf, err := fs.ReadFile(r.config.FS, file)
+	if err != nil {
+		return err
+	}
+	_, err = t.New(file).Parse(string(buf))
+	return err
+}
+
+// load loads the template set of page
+func (r *HTMLRenderer) load(name string) (*template.Template, error) {
+	t := template.New("").Funcs(r.funcs)
+	err := r.parseDir(t, r.config.LayoutDir)
+	if err != nil {
+		return nil, err
+	}
+	err = r.parseDir(t, r.config.PartialDir)
+	if err != nil {
+		return nil, err
+	}
+	err = r.parseFile(t, name)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// get returns the template set of page from cache, it will be loaded every time in dev mode
+func (r *HTMLRenderer) get(name string) (*template.Template, error) {
+	if !r.config.Dev {
+		if t, ok := r.templates.Load(name); ok {
+			return t.(*template.Template), nil
+		}
+	}
+	t, err := r.load(name)
+	if err != nil {
+		return nil, err
+	}
+	if !r.config.Dev {
+		r.templates.Store(name, t)
+	}
+	return t, nil
+}
+
+// Render renders the page of name, the default layout is used if the page defines the content template
+func (r *HTMLRenderer) Render(w io.Writer, name string, data interface{}) error {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	t, err := r.get(name)
+	if err != nil {
+		return err
+	}
+	if r.config.Layout != "" && t.Lookup(LayoutContentName) != nil {
+		return t.ExecuteTemplate(w, path.Join(r.config.LayoutDir, r.config.Layout), data)
+	}
+	return t.ExecuteTemplate(w, name, data)
+}
+
+// Render renders the template by the renderer of elton, and sets the result to body buffer.
+// The content type is set by the extension of template(default is text/html) if it's not set.
+func (c *Context) Render(name string, data interface{}) error {
+	if c.elton == nil || c.elton.Renderer == nil {
+		return ErrRendererNotSet
+	}
+	buffer := new(bytes.Buffer)
+	err := c.elton.Renderer.Render(buffer, name, data)
+	if err != nil {
+		return err
+	}
+	if c.GetHeader(HeaderContentType) == "" {
+		contentType := mime.TypeByExtension(path.Ext(name))
+		if contentType == "" {
+			contentType = MIMETextHTML
+		}
+		c.SetHeader(HeaderContentType, contentType)
+	}
+	c.BodyBuffer = buffer
+	return nil
+}
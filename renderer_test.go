@@ -0,0 +1,150 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package elton
+
+import (
+	"bytes"
+	"html/template"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReverseURL(t *testing.T) {
+	assert := assert.New(t)
+
+	url, err := ReverseURL("/users/{id}/books/{bookID:[0-9]+}", "id", 1, "bookID", 2)
+	assert.Nil(err)
+	assert.Equal("/users/1/books/2", url)
+
+	url, err = ReverseURL("/static/*", "*", "app.js")
+	assert.Nil(err)
+	assert.Equal("/static/app.js", url)
+
+	// 参数值需要转义
+	url, err = ReverseURL("/users/{id}/books", "id", "a/b?c=1 d")
+	assert.Nil(err)
+	assert.Equal("/users/a%2Fb%3Fc=1%20d/books", url)
+	url, err = ReverseURL("/static/*", "*", "js/x?y.js")
+	assert.Nil(err)
+	assert.Equal("/static/js/x%3Fy.js", url)
+
+	_, err = ReverseURL("/users/{id}")
+	assert.Equal("param id of route /users/{id} is missing", err.Error())
+
+	_, err = ReverseURL("/users/{id}", "id")
+	assert.Equal("params should be key-value pairs", err.Error())
+}
+
+func newTestHTMLRenderer(dev bool, fsys fstest.MapFS) *HTMLRenderer {
+	return NewHTMLRenderer(HTMLRendererConfig{
+		FS:     fsys,
+		Layout: "base.html",
+		Dev:    dev,
+		AssetURL: func(name string) string {
+			return "/static/" + name
+		},
+		Funcs: template.FuncMap{
+			"upper": strings.ToUpper,
+		},
+	})
+}
+
+func TestHTMLRenderer(t *testing.T) {
+	assert := assert.New(t)
+	fsys := fstest.MapFS{
+		"layouts/base.html": &fstest.MapFile{
+			Data: []byte(`<html>{{template "partials/header.html" .}}{{template "content" .}}</html>`),
+		},
+		"partials/header.html": &fstest.MapFile{
+			Data: []byte(`<script src="{{asset "app.js"}}"></script>`),
+		},
+		"users/detail.html": &fstest.MapFile{
+			Data: []byte(`{{define "content"}}<a href="{{url "/users/{id}" "id" .ID}}">{{upper .Name}}</a>{{end}}`),
+		},
+		"fragment.html": &fstest.MapFile{
+			Data: []byte(`<p>{{.Name}}</p>`),
+		},
+	}
+	data := map[string]interface{}{
+		"ID":   1,
+		"Name": "<tree>",
+	}
+
+	r := newTestHTMLRenderer(false, fsys)
+	buffer := new(bytes.Buffer)
+	err := r.Render(buffer, "users/detail.html", data)
+	assert.Nil(err)
+	assert.Equal(`<html><script src="/static/app.js"></script><a href="/users/1">&lt;TREE&gt;</a></html>`, buffer.String())
+
+	// 未定义content则不使用layout
+	buffer.Reset()
+	err = r.Render(buffer, "/fragment.html", data)
+	assert.Nil(err)
+	assert.Equal(`<p>&lt;tree&gt;</p>`, buffer.String())
+
+	err = r.Render(buffer, "notfound.html", data)
+	assert.NotNil(err)
+
+	// 非dev模式使用缓存
+	fsys["fragment.html"] = &fstest.MapFile{
+		Data: []byte(`<div>{{.Name}}</div>`),
+	}
+	buffer.Reset()
+	assert.Nil(r.Render(buffer, "fragment.html", data))
+	assert.Equal(`<p>&lt;tree&gt;</p>`, buffer.String())
+
+	// dev模式每次重新加载
+	r = newTestHTMLRenderer(true, fsys)
+	buffer.Reset()
+	assert.Nil(r.Render(buffer, "fragment.html", data))
+	assert.Equal(`<div>&lt;tree&gt;</div>`, buffer.String())
+}
+
+func TestContextRender(t *testing.T) {
+	assert := assert.New(t)
+	c := NewContext(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	assert.Equal(ErrRendererNotSet, c.Render("index.html", nil))
+
+	e := New()
+	e.Renderer = newTestHTMLRenderer(false, fstest.MapFS{
+		"index.html": &fstest.MapFile{
+			Data: []byte(`<p>{{.}}</p>`),
+		},
+		"sitemap.xml": &fstest.MapFile{
+			Data: []byte(`<urlset></urlset>`),
+		},
+	})
+	c.elton = e
+	assert.Nil(c.Render("index.html", "elton"))
+	assert.Equal(MIMETextHTML, c.GetHeader(HeaderContentType))
+	assert.Equal("<p>elton</p>", c.BodyBuffer.String())
+
+	c = NewContext(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	c.elton = e
+	assert.Nil(c.Render("sitemap.xml", nil))
+	assert.Contains(c.GetHeader(HeaderContentType), "xml")
+}
@@ -0,0 +1,156 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package elton
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	// StreamFlushInterval the interval of flushing streamed data to client
+	StreamFlushInterval = time.Second
+	// streamBufferSize the buffer size of stream writer
+	streamBufferSize = 32 * 1024
+)
+
+type (
+	// StreamIterator returns the next item of stream, io.EOF means there is no more item.
+	StreamIterator func() (interface{}, error)
+	// streamCounter writes data to response and counts the size
+	streamCounter struct {
+		c *Context
+	}
+)
+
+func (sc *streamCounter) Write(p []byte) (int, error) {
+	n, err := sc.c.Response.Write(p)
+	sc.c.streamedSize += int64(n)
+	return n, err
+}
+
+// StreamJSONArray encodes the items of iterator one by one as json array and streams them to response.
+// The data will be flushed to client periodically, and it will be aborted if the request's context is done.
+func (c *Context) StreamJSONArray(iter StreamIterator) error {
+	return c.streamJSON(MIMEApplicationJSON, iter, true)
+}
+
+// StreamNDJSON encodes the items of iterator one by one as newline delimited json and streams them to response.
+// The data will be flushed to client periodically, and it will be aborted if the request's context is done.
+func (c *Context) StreamNDJSON(iter StreamIterator) error {
+	return c.streamJSON(MIMEApplicationNDJSON, iter, false)
+}
+
+func (c *Context) streamJSON(contentType string, iter StreamIterator, isArray bool) (err error) {
+	// 数据直接写入response
+	c.Committed = true
+	header := c.Header()
+	header.Set(HeaderContentType, contentType)
+	header.Del(HeaderContentLength)
+	statusCode := c.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	c.StatusCode = statusCode
+	c.Response.WriteHeader(statusCode)
+
+	w := bufio.NewWriterSize(&streamCounter{
+		c: c,
+	}, streamBufferSize)
+	var flushedAt time.Time
+	flush := func() error {
+		err := w.Flush()
+		if err != nil {
+			return err
+		}
+		if flusher, ok := c.Response.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		flushedAt = time.Now()
+		return nil
+	}
+	var done <-chan struct{}
+	if c.Request != nil {
+		done = c.Context().Done()
+	}
+
+	if isArray {
+		err = w.WriteByte('[')
+		if err != nil {
+			return
+		}
+	}
+	count := 0
+	for {
+		// 客户端已断开或请求已取消
+		select {
+		case <-done:
+			return c.Context().Err()
+		default:
+		}
+		item, e := iter()
+		if e == io.EOF {
+			break
+		}
+		if e != nil {
+			return e
+		}
+		buf, e := json.Marshal(item)
+		if e != nil {
+			return e
+		}
+		if isArray && count != 0 {
+			err = w.WriteByte(',')
+			if err != nil {
+				return
+			}
+		}
+		_, err = w.Write(buf)
+		if err != nil {
+			return
+		}
+		if !isArray {
+			err = w.WriteByte('\n')
+			if err != nil {
+				return
+			}
+		}
+		count++
+		// 首个数据或超过刷新间隔则刷新至客户端
+		if time.Since(flushedAt) >= StreamFlushInterval {
+			err = flush()
+			if err != nil {
+				return
+			}
+		}
+	}
+	if isArray {
+		err = w.WriteByte(']')
+		if err != nil {
+			return
+		}
+	}
+	return flush()
+}
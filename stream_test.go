@@ -0,0 +1,124 @@
+// MIT License
+
+// Copyright (c) 2021 Tree Xie
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package elton
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newSliceStreamIterator(items ...interface{}) StreamIterator {
+	index := 0
+	return func() (interface{}, error) {
+		if index >= len(items) {
+			return nil, io.EOF
+		}
+		item := items[index]
+		index++
+		return item, nil
+	}
+}
+
+func TestStreamJSONArray(t *testing.T) {
+	assert := assert.New(t)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	resp := httptest.NewRecorder()
+	c := NewContext(resp, req)
+	c.SetHeader(HeaderContentLength, "100")
+	err := c.StreamJSONArray(newSliceStreamIterator(map[string]int{
+		"id": 1,
+	}, map[string]int{
+		"id": 2,
+	}))
+	assert.Nil(err)
+	assert.True(c.Committed)
+	assert.True(resp.Flushed)
+	assert.Equal(200, resp.Code)
+	assert.Equal(MIMEApplicationJSON, resp.Header().Get(HeaderContentType))
+	assert.Empty(resp.Header().Get(HeaderContentLength))
+	assert.Equal(`[{"id":1},{"id":2}]`, resp.Body.String())
+	assert.Equal(resp.Body.Len(), c.ResponseSize())
+
+	// 空数组
+	resp = httptest.NewRecorder()
+	c = NewContext(resp, req)
+	err = c.StreamJSONArray(newSliceStreamIterator())
+	assert.Nil(err)
+	assert.Equal("[]", resp.Body.String())
+}
+
+func TestStreamNDJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	resp := httptest.NewRecorder()
+	c := NewContext(resp, req)
+	c.StatusCode = 201
+	err := c.StreamNDJSON(newSliceStreamIterator(1, "a", map[string]bool{
+		"ok": true,
+	}))
+	assert.Nil(err)
+	assert.Equal(201, resp.Code)
+	assert.Equal(MIMEApplicationNDJSON, resp.Header().Get(HeaderContentType))
+	assert.Equal("1\n\"a\"\n{\"ok\":true}\n", resp.Body.String())
+	assert.Equal(resp.Body.Len(), c.ResponseSize())
+}
+
+func TestStreamError(t *testing.T) {
+	assert := assert.New(t)
+
+	// 获取数据出错
+	customErr := errors.New("abc")
+	req := httptest.NewRequest("GET", "/", nil)
+	c := NewContext(httptest.NewRecorder(), req)
+	count := 0
+	err := c.StreamNDJSON(func() (interface{}, error) {
+		count++
+		if count > 1 {
+			return nil, customErr
+		}
+		return count, nil
+	})
+	assert.Equal(customErr, err)
+
+	// 请求已取消
+	ctx, cancel := context.WithCancel(context.Background())
+	resp := httptest.NewRecorder()
+	c = NewContext(resp, req.WithContext(ctx))
+	count = 0
+	err = c.StreamJSONArray(func() (interface{}, error) {
+		count++
+		if count == 2 {
+			cancel()
+		}
+		return count, nil
+	})
+	assert.Equal(context.Canceled, err)
+	assert.Equal(2, count)
+	assert.Equal("[1", resp.Body.String())
+}